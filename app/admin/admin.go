@@ -18,8 +18,24 @@ func InitRoute(router *mux.Router) {
 		router.HandleFunc("/admin/balance", handlers.GetBalance)
 		router.HandleFunc("/admin/auth", handlers.Authentication)
 		router.HandleFunc("/admin/broadcast", handlers.Broadcast)
+		router.HandleFunc("/admin/broadcast/audience", handlers.BroadcastAudience)
+		router.HandleFunc("/admin/broadcasts", handlers.GetBroadcasts)
+		router.HandleFunc("/admin/broadcasts/pause", handlers.PauseBroadcast)
+		router.HandleFunc("/admin/broadcasts/resume", handlers.ResumeBroadcast)
+		router.HandleFunc("/admin/broadcasts/cancel", handlers.CancelBroadcast)
+		router.HandleFunc("/admin/broadcast/schedule", handlers.ScheduleBroadcast)
+		router.HandleFunc("/admin/broadcast/schedules", handlers.GetSchedules)
+		router.HandleFunc("/admin/broadcast/schedules/cancel", handlers.CancelSchedule)
 		router.HandleFunc("/admin/getactions", handlers.GetActions)
 		router.HandleFunc("/admin/subscribers", handlers.Subscribers)
 		router.HandleFunc("/admin/getluckymoney", handlers.GetLuckymoney)
+		router.HandleFunc("/admin/verify", handlers.Verify)
+		router.HandleFunc("/admin/status", handlers.Status)
+		router.HandleFunc("/admin/pushqueue", handlers.GetPushQueue)
+		router.HandleFunc("/admin/withdrawals", handlers.GetWithdrawals)
+		router.HandleFunc("/admin/withdrawals/approve", handlers.ApproveWithdrawal)
+		router.HandleFunc("/admin/withdrawals/reject", handlers.RejectWithdrawal)
+		router.HandleFunc("/admin/withdrawals/confirm", handlers.ConfirmWithdrawal)
+		router.HandleFunc("/admin/withdrawals/fail", handlers.FailWithdrawal)
 	})
 }
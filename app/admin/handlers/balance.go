@@ -2,24 +2,24 @@ package handlers
 
 import (
 	"encoding/json"
-	"math/big"
 	"net/http"
 
-	"luckybot/app/config"
+	"luckybot/app/fmath"
 	"luckybot/app/storage"
 	"luckybot/app/storage/models"
 )
 
 // 获取余额请求
 type GetBalanceRequest struct {
-	UserID int64 `json:"user_id"` // 用户ID
-	Tonce  int64 `json:"tonce"`   // 时间戳
+	UserID int64  `json:"user_id"` // 用户ID
+	Symbol string `json:"symbol"`  // 资产符号
+	Tonce  int64  `json:"tonce"`   // 时间戳
 }
 
 // 获取余额响应
 type GetBalanceRespone struct {
-	Amount *big.Float `json:"amount"` // 可用余额
-	Locked *big.Float `json:"locked"` // 锁定金额
+	Amount fmath.Decimal `json:"amount"` // 可用余额
+	Locked fmath.Decimal `json:"locked"` // 锁定金额
 }
 
 // 获取余额
@@ -43,11 +43,18 @@ func GetBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 获取资产配置
+	asset, ok := getAsset(request.Symbol)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, "unknown asset symbol"))
+		return
+	}
+
 	// 获取账户余额
-	serveCfg := config.GetServe()
 	model := models.AccountModel{}
-	account, err := model.GetAccount(request.UserID, serveCfg.Symbol)
-	if err != nil && err != storage.ErrNoBucket {
+	account, err := model.GetAccount(request.UserID, asset.Symbol)
+	if err != nil && err != storage.ErrNoBucket && err != models.ErrNoSuchTypeAccount {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write(makeErrorRespone(sessionID, err.Error()))
 		return
@@ -55,8 +62,8 @@ func GetBalance(w http.ResponseWriter, r *http.Request) {
 
 	if account == nil {
 		account = &models.Account{
-			Amount: big.NewFloat(0),
-			Locked: big.NewFloat(0),
+			Amount: fmath.Zero(asset.Precision),
+			Locked: fmath.Zero(asset.Precision),
 		}
 	}
 
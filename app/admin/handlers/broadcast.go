@@ -4,19 +4,52 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"luckybot/app/logic/pusher"
+	"luckybot/app/logic/broadcast"
 	"luckybot/app/storage/models"
 )
 
 // 广播消息请求
 type BroadcastRequest struct {
-	Message string `json:"message"` // 消息内容
-	Tonce   int64  `json:"tonce"`   // 时间戳
+	models.BroadcastContent
+	Segment *models.Segment `json:"segment"` // 受众条件
+	Tonce   int64           `json:"tonce"`   // 时间戳
+}
+
+// 预估受众请求
+type BroadcastAudienceRequest struct {
+	Segment *models.Segment `json:"segment"` // 受众条件
+	Tonce   int64           `json:"tonce"`   // 时间戳
+}
+
+// 预估受众响应
+type BroadcastAudienceRespone struct {
+	Count int `json:"count"` // 受众数量
 }
 
 // 广播消息响应
 type BroadcastRespone struct {
-	OK bool `json:"ok"` // 是否成功
+	OK        bool              `json:"ok"`        // 是否成功
+	Broadcast *models.Broadcast `json:"broadcast"` // 广播任务
+}
+
+// 获取广播任务请求
+type GetBroadcastsRequest struct {
+	Offset uint  `json:"offset"` // 偏移量
+	Limit  uint  `json:"limit"`  // 返回数量
+	Tonce  int64 `json:"tonce"`  // 时间戳
+}
+
+// 获取广播任务响应
+type GetBroadcastsRespone struct {
+	Sum    uint                `json:"sum"`    // 任务总量
+	Count  int                 `json:"count"`  // 返回数量
+	Result []*models.Broadcast `json:"result"` // 任务列表
+}
+
+// 控制广播任务请求
+type ControlBroadcastRequest struct {
+	ID    uint64 `json:"id"`    // 任务ID
+	Tonce int64  `json:"tonce"` // 时间戳
 }
 
 // 广播消息
@@ -34,34 +67,175 @@ func Broadcast(w http.ResponseWriter, r *http.Request) {
 
 	// 解析请求参数
 	var request BroadcastRequest
-	if err := json.Unmarshal(data, &request); err != nil || len(request.Message) == 0 {
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 创建广播任务
+	job, err := broadcast.Create(&request.BroadcastContent, request.Segment)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write(makeErrorRespone(sessionID, err.Error()))
 		return
 	}
 
-	// 广播消息
-	var jsb []byte
-	model := models.SubscriberModel{}
-	subscribers, err := model.GetSubscribers()
+	respone := BroadcastRespone{OK: true, Broadcast: job}
+	jsb, err := json.Marshal(&respone)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write(makeErrorRespone(sessionID, err.Error()))
 		return
 	}
-	for _, userID := range subscribers {
-		pusher.Post(userID, request.Message, true, nil)
+
+	// 返回结果
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
+
+// 预估广播受众
+func BroadcastAudience(w http.ResponseWriter, r *http.Request) {
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request BroadcastAudienceRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
 	}
 
-	respone := BroadcastRespone{OK: true}
-	jsb, err = json.Marshal(&respone)
+	// 计算受众数量
+	receivers, err := broadcast.Audience(request.Segment)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	respone := BroadcastAudienceRespone{Count: len(receivers)}
+	jsb, err := json.Marshal(&respone)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write(makeErrorRespone(sessionID, err.Error()))
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
 
-	// 返回结果
+// 获取广播任务
+func GetBroadcasts(w http.ResponseWriter, r *http.Request) {
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request GetBroadcastsRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 获取任务列表
+	model := models.BroadcastModel{}
+	broadcasts, sum, err := model.GetBroadcasts(request.Offset, request.Limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回任务列表
+	respone := GetBroadcastsRespone{
+		Sum:    sum,
+		Count:  len(broadcasts),
+		Result: broadcasts,
+	}
+	jsb, err := json.Marshal(&respone)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
+
+// 暂停广播任务
+func PauseBroadcast(w http.ResponseWriter, r *http.Request) {
+	controlBroadcast(w, r, broadcast.Pause)
+}
+
+// 恢复广播任务
+func ResumeBroadcast(w http.ResponseWriter, r *http.Request) {
+	controlBroadcast(w, r, broadcast.Resume)
+}
+
+// 取消广播任务
+func CancelBroadcast(w http.ResponseWriter, r *http.Request) {
+	controlBroadcast(w, r, broadcast.Cancel)
+}
+
+// 控制广播任务
+func controlBroadcast(w http.ResponseWriter, r *http.Request,
+	control func(uint64) (*models.Broadcast, error)) {
+
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request ControlBroadcastRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 执行控制操作
+	job, err := control(request.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回任务信息
+	jsb, err := json.Marshal(job)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(makeRespone(sessionID, jsb))
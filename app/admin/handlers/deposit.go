@@ -2,10 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
-	"math/big"
 	"net/http"
 
-	"luckybot/app/config"
 	"luckybot/app/fmath"
 	"luckybot/app/logic/handlers/utils"
 	"luckybot/app/logic/pusher"
@@ -14,15 +12,16 @@ import (
 
 // 充值请求
 type DepositRequest struct {
-	UserID int64      `json:"user_id"` // 用户ID
-	Amount *big.Float `json:"amount"`  // 充值金额
-	Tonce  int64      `json:"tonce"`   // 时间戳
+	UserID int64          `json:"user_id"` // 用户ID
+	Symbol string         `json:"symbol"`  // 资产符号
+	Amount *fmath.Decimal `json:"amount"`  // 充值金额
+	Tonce  int64          `json:"tonce"`   // 时间戳
 }
 
 // 充值响应
 type DepositRespone struct {
-	Amount *big.Float `json:"amount"` // 可用余额
-	Locked *big.Float `json:"locked"` // 锁定金额
+	Amount fmath.Decimal `json:"amount"` // 可用余额
+	Locked fmath.Decimal `json:"locked"` // 锁定金额
 }
 
 // 充值资产
@@ -46,26 +45,42 @@ func Deposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	zero := big.NewFloat(0)
-	if request.Amount != nil {
-		request.Amount.SetPrec(fmath.Prec())
-	}
-	if request.Amount == nil || request.Amount.Cmp(zero) <= 0 {
+	if request.Amount == nil || request.Amount.Sign() <= 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write(makeErrorRespone(sessionID, "amount must be greater than 0"))
 		return
 	}
 
+	// 检查资产精度
+	asset, ok := getAsset(request.Symbol)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, "unknown asset symbol"))
+		return
+	}
+	if request.Amount.Prec() > asset.Precision &&
+		request.Amount.Cmp(request.Amount.Round(asset.Precision)) != 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, "amount exceeds asset precision"))
+		return
+	}
+	amount := request.Amount.Round(asset.Precision)
+
 	// 为用户充值
-	serveCfg := config.GetServe()
-	model := models.AccountModel{}
-	account, err := model.Deposit(request.UserID, serveCfg.Symbol, request.Amount)
+	version := &models.Version{
+		Balance: &amount,
+		Reason:  models.ReasonSystem,
+	}
+	model := models.LedgerModel{}
+	journal := models.NewJournal(asset.Symbol).Deposit(request.UserID, amount).Record(request.UserID, version)
+	accounts, err := model.Post(journal)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write(makeErrorRespone(sessionID, err.Error()))
 		return
 	}
 
+	account := accounts[request.UserID]
 	respone := DepositRespone{Amount: account.Amount, Locked: account.Locked}
 	jsb, err := json.Marshal(respone)
 	if err != nil {
@@ -74,19 +89,8 @@ func Deposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 写入操作记录
-	versionModel := models.AccountVersionModel{}
-	version, err := versionModel.InsertVersion(request.UserID, &models.Version{
-		Symbol:  serveCfg.Symbol,
-		Balance: request.Amount,
-		Amount:  account.Amount,
-		Reason:  models.ReasonSystem,
-	})
-
 	// 推送充值通知
-	if err == nil {
-		pusher.Post(request.UserID, utils.MakeHistoryMessage(request.UserID, version), true, nil)
-	}
+	pusher.Post(request.UserID, utils.MakeHistoryMessage(request.UserID, version), true, nil)
 
 	// 返回余额信息
 	w.Header().Set("Content-Type", "application/json")
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+
+	"luckybot/app/config"
 )
 
 // 身份验证
@@ -22,6 +24,15 @@ func authentication(r *http.Request) (string, []byte, bool) {
 	return sessionID, src, true
 }
 
+// 获取资产配置
+func getAsset(symbol string) (config.Asset, bool) {
+	serveCfg := config.GetServe()
+	if len(symbol) == 0 {
+		return serveCfg.Assets[0], true
+	}
+	return serveCfg.GetAsset(symbol)
+}
+
 // 生成响应
 func makeRespone(sessionID string, result []byte) []byte {
 	respone := struct {
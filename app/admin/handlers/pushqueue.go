@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"luckybot/app/logic/pusher"
+	"luckybot/app/storage/models"
+)
+
+// 获取推送队列请求
+type GetPushQueueRequest struct {
+	Offset uint  `json:"offset"` // 死信偏移量
+	Limit  uint  `json:"limit"`  // 死信数量
+	Tonce  int64 `json:"tonce"`  // 时间戳
+}
+
+// 获取推送队列响应
+type GetPushQueueRespone struct {
+	pusher.QueueStats
+	DeadLetters []*models.PushMessage `json:"dead_letters"` // 死信列表
+}
+
+// 获取推送队列
+func GetPushQueue(w http.ResponseWriter, r *http.Request) {
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request GetPushQueueRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 获取队列状态
+	stats, err := pusher.GetQueueStats()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 获取死信列表
+	model := models.PushQueueModel{}
+	deadLetters, err := model.GetDeadLetters(request.Offset, request.Limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回队列状态
+	respone := GetPushQueueRespone{
+		QueueStats:  stats,
+		DeadLetters: deadLetters,
+	}
+	jsb, err := json.Marshal(&respone)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"luckybot/app/logic/broadcast"
+	"luckybot/app/storage/models"
+)
+
+// 定时广播请求
+type ScheduleBroadcastRequest struct {
+	models.BroadcastContent
+	Segment  *models.Segment `json:"segment"`  // 受众条件
+	RunAt    int64           `json:"run_at"`   // 执行时间
+	Interval uint32          `json:"interval"` // 重复间隔
+	Tonce    int64           `json:"tonce"`    // 时间戳
+}
+
+// 获取定时广播请求
+type GetSchedulesRequest struct {
+	State  models.ScheduleState `json:"state"`  // 定时状态
+	Offset uint                 `json:"offset"` // 偏移量
+	Limit  uint                 `json:"limit"`  // 返回数量
+	Tonce  int64                `json:"tonce"`  // 时间戳
+}
+
+// 获取定时广播响应
+type GetSchedulesRespone struct {
+	Sum    uint                        `json:"sum"`    // 定时总量
+	Count  int                         `json:"count"`  // 返回数量
+	Result []*models.BroadcastSchedule `json:"result"` // 定时列表
+}
+
+// 取消定时广播请求
+type CancelScheduleRequest struct {
+	ID    uint64 `json:"id"`    // 定时ID
+	Tonce int64  `json:"tonce"` // 时间戳
+}
+
+// 创建定时广播
+func ScheduleBroadcast(w http.ResponseWriter, r *http.Request) {
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request ScheduleBroadcastRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 创建定时广播
+	schedule, err := broadcast.Schedule(&request.BroadcastContent, request.Segment,
+		request.RunAt, request.Interval)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回定时信息
+	jsb, err := json.Marshal(schedule)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
+
+// 获取定时广播
+func GetSchedules(w http.ResponseWriter, r *http.Request) {
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request GetSchedulesRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 获取定时列表
+	model := models.BroadcastScheduleModel{}
+	schedules, sum, err := model.GetSchedules(request.State, request.Offset, request.Limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回定时列表
+	respone := GetSchedulesRespone{
+		Sum:    sum,
+		Count:  len(schedules),
+		Result: schedules,
+	}
+	jsb, err := json.Marshal(&respone)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
+
+// 取消定时广播
+func CancelSchedule(w http.ResponseWriter, r *http.Request) {
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request CancelScheduleRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 取消定时广播
+	schedule, err := broadcast.CancelSchedule(request.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回定时信息
+	jsb, err := json.Marshal(schedule)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"luckybot/app/dispatcher"
+	poll "luckybot/app/poller"
+)
+
+// 服务状态请求
+type StatusRequest struct {
+	Tonce int64 `json:"tonce"` // 时间戳
+}
+
+// 服务状态响应
+type StatusRespone struct {
+	Dispatcher dispatcher.Stats `json:"dispatcher"` // 更新分发统计
+	Poller     poll.Health      `json:"poller"`     // 轮询健康状态
+}
+
+// 获取服务状态
+func Status(w http.ResponseWriter, r *http.Request) {
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request StatusRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回服务状态
+	respone := StatusRespone{
+		Dispatcher: dispatcher.Dispatcher.Stats(),
+		Poller:     poll.GetHealth(),
+	}
+	jsb, err := json.Marshal(&respone)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
@@ -9,7 +9,17 @@ import (
 
 // 获取订户请求
 type GetSubscribersRequest struct {
-	Tonce int64 `json:"tonce"` // 时间戳
+	models.SubscriberFilter
+	Offset uint  `json:"offset"` // 偏移量
+	Limit  uint  `json:"limit"`  // 返回数量
+	Tonce  int64 `json:"tonce"`  // 时间戳
+}
+
+// 获取订户响应
+type GetSubscribersRespone struct {
+	Sum    uint                 `json:"sum"`    // 订户总量
+	Count  int                  `json:"count"`  // 返回数量
+	Result []*models.Subscriber `json:"result"` // 订户列表
 }
 
 // 获取订户
@@ -35,15 +45,24 @@ func Subscribers(w http.ResponseWriter, r *http.Request) {
 
 	// 查询订阅用户
 	model := models.SubscriberModel{}
-	users, err := model.GetSubscribers()
+	subscribers, sum, err := model.QuerySubscribers(&request.SubscriberFilter, request.Offset, request.Limit)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		if err == models.ErrInvalidSort {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		w.Write(makeErrorRespone(sessionID, err.Error()))
 		return
 	}
 
 	// 返回处理结果
-	jsb, err := json.Marshal(&users)
+	respone := GetSubscribersRespone{
+		Sum:    sum,
+		Count:  len(subscribers),
+		Result: subscribers,
+	}
+	jsb, err := json.Marshal(&respone)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write(makeErrorRespone(sessionID, err.Error()))
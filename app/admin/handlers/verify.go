@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"luckybot/app/storage/models"
+)
+
+// 对账请求
+type VerifyRequest struct {
+	Plan  bool  `json:"plan"`  // 生成修复计划
+	Tonce int64 `json:"tonce"` // 时间戳
+}
+
+// 执行对账
+func Verify(w http.ResponseWriter, r *http.Request) {
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request VerifyRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 检查账户数据
+	model := models.VerifyModel{}
+	report, err := model.Verify(request.Plan)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回对账报告
+	jsb, err := json.Marshal(report)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"luckybot/app/logic/withdraw"
+	"luckybot/app/storage/models"
+)
+
+// 获取提现请求
+type GetWithdrawalsRequest struct {
+	State  models.WithdrawState `json:"state"`  // 提现状态
+	Offset uint                 `json:"offset"` // 偏移量
+	Limit  uint                 `json:"limit"`  // 返回数量
+	Tonce  int64                `json:"tonce"`  // 时间戳
+}
+
+// 获取提现响应
+type GetWithdrawalsRespone struct {
+	Sum    uint               `json:"sum"`    // 提现总量
+	Count  int                `json:"count"`  // 返回数量
+	Result []*models.Withdraw `json:"result"` // 提现列表
+}
+
+// 审核提现请求
+type ReviewWithdrawalRequest struct {
+	ID     uint64 `json:"id"`     // 提现ID
+	Reason string `json:"reason"` // 拒绝原因
+	TxID   string `json:"txid"`   // 交易ID
+	Tonce  int64  `json:"tonce"`  // 时间戳
+}
+
+// 获取提现列表
+func GetWithdrawals(w http.ResponseWriter, r *http.Request) {
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	request := GetWithdrawalsRequest{State: models.WithdrawReview}
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 获取提现列表
+	model := models.WithdrawModel{}
+	withdraws, sum, err := model.GetWithdraws(request.State, request.Offset, request.Limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回提现列表
+	respone := GetWithdrawalsRespone{
+		Sum:    sum,
+		Count:  len(withdraws),
+		Result: withdraws,
+	}
+	jsb, err := json.Marshal(&respone)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
+
+// 审核通过提现
+func ApproveWithdrawal(w http.ResponseWriter, r *http.Request) {
+	reviewWithdrawal(w, r, func(request *ReviewWithdrawalRequest) (*models.Withdraw, error) {
+		return withdraw.Approve(request.ID)
+	})
+}
+
+// 审核拒绝提现
+func RejectWithdrawal(w http.ResponseWriter, r *http.Request) {
+	reviewWithdrawal(w, r, func(request *ReviewWithdrawalRequest) (*models.Withdraw, error) {
+		return withdraw.Reject(request.ID, request.Reason)
+	})
+}
+
+// 确认超时提现
+func ConfirmWithdrawal(w http.ResponseWriter, r *http.Request) {
+	reviewWithdrawal(w, r, func(request *ReviewWithdrawalRequest) (*models.Withdraw, error) {
+		return withdraw.ConfirmTimedOut(request.ID, request.TxID)
+	})
+}
+
+// 取消超时提现
+func FailWithdrawal(w http.ResponseWriter, r *http.Request) {
+	reviewWithdrawal(w, r, func(request *ReviewWithdrawalRequest) (*models.Withdraw, error) {
+		return withdraw.FailTimedOut(request.ID, request.Reason)
+	})
+}
+
+// 审核提现
+func reviewWithdrawal(w http.ResponseWriter, r *http.Request,
+	review func(*ReviewWithdrawalRequest) (*models.Withdraw, error)) {
+
+	// 跨域访问
+	allowAccessControl(w)
+
+	// 验证权限
+	sessionID, data, ok := authentication(r)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write(makeErrorRespone("", ""))
+		return
+	}
+
+	// 解析请求参数
+	var request ReviewWithdrawalRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 执行审核操作
+	record, err := review(&request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+
+	// 返回提现信息
+	jsb, err := json.Marshal(record)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(makeErrorRespone(sessionID, err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(makeRespone(sessionID, jsb))
+}
@@ -1,36 +1,197 @@
 package config
 
 import (
+	"errors"
 	"io/ioutil"
-	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/zhangpanyi/basebot/logger"
 
 	"github.com/fsnotify/fsnotify"
-	"gopkg.in/yaml.v2"
+	"luckybot/app/fmath"
 )
 
+// 资产配置
+type Asset struct {
+	Name                 string  `yaml:"name"`                   // 资产名称
+	Symbol               string  `yaml:"symbol"`                 // 资产符号
+	Precision            int     `yaml:"precision"`              // 资产精度
+	WithdrawFee          float64 `yaml:"withdraw_fee"`           // 提现手续费
+	ReviewThreshold      float64 `yaml:"review_threshold"`       // 单笔审核阈值
+	ReviewDailyThreshold float64 `yaml:"review_daily_threshold"` // 每日审核阈值
+	MinWithdraw          float64 `yaml:"min_withdraw"`           // 最小提现数量
+	DailyWithdrawLimit   float64 `yaml:"daily_withdraw_limit"`   // 每日提现上限
+	WeeklyWithdrawLimit  float64 `yaml:"weekly_withdraw_limit"`  // 每周提现上限
+	DailyWithdrawCount   int     `yaml:"daily_withdraw_count"`   // 每日提现次数
+	DepositCooldown      uint32  `yaml:"deposit_cooldown"`       // 充值后冷却时间
+}
+
+// 转换为定点小数
+func (asset *Asset) decimal(f float64) fmath.Decimal {
+	value, _ := fmath.FromFloat(f, asset.Precision)
+	return value
+}
+
+// 获取提现手续费
+func (asset *Asset) Fee() fmath.Decimal {
+	return asset.decimal(asset.WithdrawFee)
+}
+
+// 获取最小提现数量
+func (asset *Asset) MinWithdrawAmount() fmath.Decimal {
+	return asset.decimal(asset.MinWithdraw)
+}
+
+// 获取每日提现上限
+func (asset *Asset) DailyLimit() fmath.Decimal {
+	return asset.decimal(asset.DailyWithdrawLimit)
+}
+
+// 获取每周提现上限
+func (asset *Asset) WeeklyLimit() fmath.Decimal {
+	return asset.decimal(asset.WeeklyWithdrawLimit)
+}
+
+// 提现是否需要审核
+// 单笔金额或当日累计金额超过阈值时需要审核，阈值为0表示不限制
+func (asset *Asset) NeedReview(amount, daily fmath.Decimal) bool {
+	threshold := asset.decimal(asset.ReviewThreshold)
+	if threshold.Sign() > 0 && amount.Cmp(threshold) > 0 {
+		return true
+	}
+
+	threshold = asset.decimal(asset.ReviewDailyThreshold)
+	if threshold.Sign() > 0 && daily.Add(amount).Cmp(threshold) > 0 {
+		return true
+	}
+	return false
+}
+
+// Webhook配置
+type Webhook struct {
+	Enable         bool   `yaml:"enable"`          // 启用Webhook
+	URL            string `yaml:"url"`             // 外部访问地址
+	SecretPath     string `yaml:"secret_path"`     // 秘密路径
+	SecretToken    string `yaml:"secret_token"`    // 秘密令牌
+	Certificate    string `yaml:"certificate"`     // 公钥证书路径
+	PrivateKey     string `yaml:"private_key"`     // 私钥路径
+	MaxConnections int32  `yaml:"max_connections"` // 最大连接数
+}
+
+// 匹配Webhook秘密
+var reMathWebhookSecret = regexp.MustCompile("^[A-Za-z0-9_-]{1,256}$")
+
+// 检查Webhook配置
+func (webhook *Webhook) check() []error {
+	if !webhook.Enable {
+		return nil
+	}
+
+	errs := make([]error, 0)
+	if !strings.HasPrefix(webhook.URL, "https://") {
+		errs = append(errs, errors.New("webhook url must be https"))
+	}
+	if !reMathWebhookSecret.MatchString(webhook.SecretPath) {
+		errs = append(errs, errors.New("invalid webhook secret path"))
+	}
+	if len(webhook.SecretToken) > 0 && !reMathWebhookSecret.MatchString(webhook.SecretToken) {
+		errs = append(errs, errors.New("invalid webhook secret token"))
+	}
+	if len(webhook.PrivateKey) > 0 && len(webhook.Certificate) == 0 {
+		errs = append(errs, errors.New("webhook private key without certificate"))
+	}
+	if webhook.MaxConnections < 0 || webhook.MaxConnections > 100 {
+		errs = append(errs, errors.New("invalid webhook max connections"))
+	}
+	return errs
+}
+
 // 服务配置
 type Serve struct {
-	Host              string  `yaml:"host"`                 // 主机地址
-	Port              int     `yaml:"port"`                 // HTTP端口
-	Test              bool    `yaml:"test"`                 // 测试模式
-	APIAccess         string  `yaml:"api_access"`           // API接入点
-	SupportStaff      *int64  `yaml:"support_staff"`        // 电报客服ID
-	SecretKey         string  `yaml:"secret_key"`           // 验证码密钥
-	Token             string  `yaml:"token"`                // 机器人token
-	Name              string  `yaml:"name"`                 // 资产名称
-	Symbol            string  `yaml:"symbol"`               // 资产符号
-	Precision         int     `yaml:"precision"`            // 资产精度
-	WithdrawFee       float64 `yaml:"withdraw_fee"`         // 提现手续费
-	BolTDBPath        string  `yaml:"boltdb_path"`          // BoltDB路径
-	Languages         string  `yaml:"languages"`            // 语言配置路径
-	Expire            uint32  `yaml:"expire"`               // 红包过期时间
-	MaxMessageLen     int     `yaml:"max_message_len"`      // 最大留言长度
-	MaxHistoryTextLen int     `yaml:"max_history_text_len"` // 历史文本长度
-	ThumbURL          string  `yaml:"thumb_url"`            // 红包缩略图URL
+	Host               string  `yaml:"host"`                       // 主机地址
+	Port               int     `yaml:"port"`                       // HTTP端口
+	Test               bool    `yaml:"test"`                       // 测试模式
+	APIAccess          string  `yaml:"api_access"`                 // API接入点
+	SupportStaff       *int64  `yaml:"support_staff"`              // 电报客服ID
+	SecretKey          string  `yaml:"secret_key"`                 // 验证码密钥
+	Token              string  `yaml:"token"`                      // 机器人token
+	Assets             []Asset `yaml:"assets"`                     // 资产列表
+	BolTDBPath         string  `yaml:"boltdb_path"`                // BoltDB路径
+	Languages          string  `yaml:"languages"`                  // 语言配置路径
+	Expire             uint32  `yaml:"expire"`                     // 红包过期时间
+	WithdrawTimeout    uint32  `yaml:"withdraw_timeout"`           // 提现超时时间
+	WithdrawRetries    int     `yaml:"withdraw_retries"`           // 提现重试次数
+	WithdrawIdempotent bool    `yaml:"withdraw_idempotent"`        // 脚本按提现ID幂等
+	WithdrawNoTimeout  bool    `yaml:"withdraw_no_timeout"`        // 提现一直等待结果
+	WithdrawAutoUnlock bool    `yaml:"withdraw_unlock_on_timeout"` // 超时后解锁资金
+	MaxMessageLen      int     `yaml:"max_message_len"`            // 最大留言长度
+	MaxHistoryTextLen  int     `yaml:"max_history_text_len"`       // 历史文本长度
+	ThumbURL           string  `yaml:"thumb_url"`                  // 红包缩略图URL
+	Webhook            Webhook `yaml:"webhook"`                    // Webhook配置
+	DispatchWorkers    int     `yaml:"dispatch_workers"`           // 更新处理协程数量
+	DispatchQueueSize  int     `yaml:"dispatch_queue_size"`        // 更新队列长度
+	UnhealthyAfter     uint32  `yaml:"unhealthy_after"`            // 轮询失败告警时间
+	BroadcastRate      int     `yaml:"broadcast_rate"`             // 广播发送速率
+	DefaultLanguage    string  `yaml:"default_language"`           // 默认语言
+}
+
+// 获取资产配置
+func (serve *Serve) GetAsset(symbol string) (Asset, bool) {
+	for _, asset := range serve.Assets {
+		if asset.Symbol == symbol {
+			return asset, true
+		}
+	}
+	return Asset{}, false
+}
+
+// 检查资产配置
+func (serve *Serve) checkAssets() []error {
+	if len(serve.Assets) == 0 {
+		return []error{errors.New("no assets configured")}
+	}
+
+	errs := make([]error, 0)
+	symbols := make(map[string]bool)
+	for _, asset := range serve.Assets {
+		if len(asset.Symbol) == 0 {
+			errs = append(errs, errors.New("asset symbol is empty"))
+			continue
+		}
+		if symbols[asset.Symbol] {
+			errs = append(errs, errors.New("duplicate asset symbol: "+asset.Symbol))
+		}
+		symbols[asset.Symbol] = true
+		if asset.Precision < 0 {
+			errs = append(errs, errors.New("invalid asset precision: "+asset.Symbol))
+			continue
+		}
+		if _, ok := fmath.FromFloat(asset.WithdrawFee, asset.Precision); !ok {
+			errs = append(errs, errors.New("invalid asset withdraw fee: "+asset.Symbol))
+		}
+		if _, ok := fmath.FromFloat(asset.ReviewThreshold, asset.Precision); !ok {
+			errs = append(errs, errors.New("invalid asset review threshold: "+asset.Symbol))
+		}
+		if _, ok := fmath.FromFloat(asset.ReviewDailyThreshold, asset.Precision); !ok {
+			errs = append(errs, errors.New("invalid asset review daily threshold: "+asset.Symbol))
+		}
+		if _, ok := fmath.FromFloat(asset.MinWithdraw, asset.Precision); !ok {
+			errs = append(errs, errors.New("invalid asset min withdraw: "+asset.Symbol))
+		}
+		if _, ok := fmath.FromFloat(asset.DailyWithdrawLimit, asset.Precision); !ok {
+			errs = append(errs, errors.New("invalid asset daily withdraw limit: "+asset.Symbol))
+		}
+		if _, ok := fmath.FromFloat(asset.WeeklyWithdrawLimit, asset.Precision); !ok {
+			errs = append(errs, errors.New("invalid asset weekly withdraw limit: "+asset.Symbol))
+		}
+		if asset.DailyWithdrawCount < 0 {
+			errs = append(errs, errors.New("invalid asset daily withdraw count: "+asset.Symbol))
+		}
+	}
+	return errs
 }
 
 // 配置解析器
@@ -40,15 +201,17 @@ type parser interface {
 
 // 配置管理器
 type Manager struct {
-	serve      *Serve
+	serve      atomic.Value
 	languges   *Languges
 	watcher    *fsnotify.Watcher
 	fileparser map[string]parser
+	lock       sync.Mutex
+	handlers   []ServeHandler
 }
 
 // 获取服务配置
 func GetServe() Serve {
-	return *globalManager.serve
+	return *globalManager.serve.Load().(*Serve)
 }
 
 // 获取语言配置
@@ -57,42 +220,62 @@ func GetLanguge() *Languges {
 }
 
 // 加载配置文件
-func LoadConfig(path string) {
+// 配置有误时返回所有错误
+func LoadConfig(path string) error {
+	var err error
 	once.Do(func() {
-		// 创建观察器
-		fileparser := make(map[string]parser)
-		watcher, err := fsnotify.NewWatcher()
-		if err != nil {
-			panic(err)
-		}
+		err = loadConfig(path)
+	})
+	return err
+}
 
-		// 加载主配置
-		data, err := ioutil.ReadFile(path)
-		if err != nil {
-			panic(err)
-		}
-		serve := Serve{}
-		err = yaml.Unmarshal(data, &serve)
-		if err != nil {
-			panic(err)
-		}
+// 加载配置文件
+func loadConfig(path string) error {
+	// 加载主配置
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	serve, err := parseServe(data)
+	if serve == nil {
+		return err
+	}
+	errs := make(ConfigError, 0)
+	if err != nil {
+		errs = append(errs, err.(ConfigError)...)
+	}
 
-		// 加载语言包配置
-		languages, files := readLanguages(serve.Languages)
-		for _, filename := range files {
-			watcher.Add(filename)
-			fileparser[filename] = languages
-		}
+	// 加载语言包配置
+	languages, files, err := readLanguages(serve.Languages, serve.DefaultLanguage)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
 
-		// 初始化全局配置
-		globalManager = &Manager{
-			serve:      &serve,
-			languges:   languages,
-			fileparser: fileparser,
-			watcher:    watcher,
-		}
-		go globalManager.watch()
-	})
+	// 创建观察器
+	fileparser := make(map[string]parser)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	for _, filename := range files {
+		watcher.Add(filename)
+		fileparser[filename] = languages
+	}
+
+	// 初始化全局配置
+	globalManager = &Manager{
+		languges:   languages,
+		fileparser: fileparser,
+		watcher:    watcher,
+	}
+	globalManager.serve.Store(serve)
+	watcher.Add(path)
+	fileparser[path] = &serveParser{manager: globalManager}
+	go globalManager.watch()
+	return nil
 }
 
 // 全局配置管理器
@@ -111,7 +294,7 @@ func (m *Manager) watch() {
 					if err == nil {
 						err = handler.parse(data)
 						if err != nil {
-							logger.Warnf("File notify: parse failed, %v, %v", evt.Name, err)
+							logger.Warnf("File notify: refuse to reload, %v, %v", evt.Name, err)
 						} else {
 							logger.Infof("File notify: realod file finished, %v", evt.Name)
 						}
@@ -129,30 +312,25 @@ func (m *Manager) watch() {
 }
 
 // 读取语言包配置
-func readLanguages(dir string) (*Languges, []string) {
-	files, err := ioutil.ReadDir(dir)
+// 以默认语言为参考检查所有语言包，存在错误时拒绝启动
+func readLanguages(dir, def string) (*Languges, []string, error) {
+	packs, files, err := loadLanguageFiles(dir)
 	if err != nil {
-		panic(err)
-	}
-
-	languges := NewLanguges()
-	paths := make([]string, 0)
-	for _, v := range files {
-		if !v.IsDir() {
-			ext := strings.ToLower(filepath.Ext(v.Name()))
-			if ext == ".lang" {
-				fullname := dir + string(filepath.Separator) + v.Name()
-				data, err := ioutil.ReadFile(fullname)
-				if err != nil {
-					panic(err)
-				}
-				err = languges.parse(data)
-				if err != nil {
-					panic(err)
-				}
-				paths = append(paths, fullname)
-			}
-		}
+		return nil, nil, err
+	}
+	issues, err := checkLanguages(def, packs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = languageErrors(issues); err != nil {
+		return nil, nil, err
+	}
+
+	languges := NewLanguges(def)
+	paths := make([]string, 0, len(files))
+	for code, lang := range packs {
+		languges.priv[code] = lang
+		paths = append(paths, files[code])
 	}
-	return languges, paths
+	return languges, paths, nil
 }
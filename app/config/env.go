@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// 环境变量前缀
+const EnvPrefix = "LUCKYBOT_"
+
+// 读取环境变量覆盖配置
+// 变量名为前缀加大写的配置项名，嵌套配置以下划线连接(如 LUCKYBOT_WEBHOOK_URL)，
+// 字符串原样使用，其它类型按 YAML 解析，列表可以使用 JSON 格式
+func applyEnv(serve *Serve, lookup func(string) (string, bool)) []error {
+	return applyEnvValue(reflect.ValueOf(serve).Elem(), EnvPrefix, lookup)
+}
+
+// 覆盖结构体字段
+func applyEnvValue(value reflect.Value, prefix string, lookup func(string) (string, bool)) []error {
+	errs := make([]error, 0)
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if len(tag) == 0 || tag == "-" {
+			continue
+		}
+
+		name := prefix + strings.ToUpper(tag)
+		if field.Type.Kind() == reflect.Struct {
+			errs = append(errs, applyEnvValue(value.Field(i), name+"_", lookup)...)
+			continue
+		}
+
+		env, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if field.Type.Kind() == reflect.String {
+			value.Field(i).SetString(env)
+			continue
+		}
+
+		ptr := reflect.New(field.Type)
+		if err := yaml.Unmarshal([]byte(env), ptr.Interface()); err != nil {
+			errs = append(errs, fmt.Errorf("invalid environment variable %s, %v", name, err))
+			continue
+		}
+		value.Field(i).Set(ptr.Elem())
+	}
+	return errs
+}
@@ -0,0 +1,245 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 语言包问题类型
+const (
+	IssueMissing = "missing" // 缺少配置项
+	IssueExtra   = "extra"   // 多余配置项
+	IssueFormat  = "format"  // 格式化参数不一致
+)
+
+// 语言包问题
+type LanguageIssue struct {
+	Code   string `json:"code"`             // 语言代码
+	Key    string `json:"key"`              // 配置项
+	Kind   string `json:"kind"`             // 问题类型
+	Detail string `json:"detail,omitempty"` // 详细信息
+}
+
+// 是否为错误
+// 多余的配置项不影响使用，只作为警告
+func (issue *LanguageIssue) IsError() bool {
+	return issue.Kind != IssueExtra
+}
+
+func (issue *LanguageIssue) String() string {
+	if len(issue.Detail) == 0 {
+		return fmt.Sprintf("%s: %s %s", issue.Code, issue.Kind, issue.Key)
+	}
+	return fmt.Sprintf("%s: %s %s, %s", issue.Code, issue.Kind, issue.Key, issue.Detail)
+}
+
+// 语言包检查错误
+type LanguageError []LanguageIssue
+
+func (e LanguageError) Error() string {
+	items := make([]string, 0, len(e))
+	for i := range e {
+		items = append(items, e[i].String())
+	}
+	return "invalid language packs: " + strings.Join(items, "; ")
+}
+
+// 格式化参数
+var reMathFormatVerb = regexp.MustCompile(`%[-+# 0]*(\[(\d+)\])?(\d+|\*)?(\.(\d+|\*)?)?([a-zA-Z%])`)
+
+// 解析格式化参数
+// 返回参数位置到格式化动词的映射，支持 %[n]s 形式的显式位置
+func formatArgs(format string) map[int]string {
+	args := make(map[int]string)
+	index := 0
+	for _, match := range reMathFormatVerb.FindAllStringSubmatch(format, -1) {
+		verb := match[6]
+		if verb == "%" {
+			continue
+		}
+		if len(match[2]) > 0 {
+			n, err := strconv.Atoi(match[2])
+			if err == nil && n > 0 {
+				index = n - 1
+			}
+		}
+		if match[3] == "*" {
+			args[index] = "*"
+			index++
+		}
+		if match[5] == "*" {
+			args[index] = "*"
+			index++
+		}
+		args[index] = verb
+		index++
+	}
+	return args
+}
+
+// 格式化参数描述
+func describeArgs(args map[int]string) string {
+	indexes := make([]int, 0, len(args))
+	for index := range args {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	verbs := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		verbs = append(verbs, fmt.Sprintf("%d:%%%s", index+1, args[index]))
+	}
+	return "[" + strings.Join(verbs, " ") + "]"
+}
+
+// 比较格式化参数
+func sameArgs(a, b map[int]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index, verb := range a {
+		if b[index] != verb {
+			return false
+		}
+	}
+	return true
+}
+
+// 检查语言包
+// 以参考语言包为准，检查缺少、多余的配置项和格式化参数
+func checkLanguage(reference, lang privLanguges) []LanguageIssue {
+	code := lang["lng_language_code"]
+	issues := make([]LanguageIssue, 0)
+	for key, value := range reference {
+		if key == "lng_language_code" || key == "lng_language_name" {
+			continue
+		}
+
+		other, ok := lang[key]
+		if !ok {
+			issues = append(issues, LanguageIssue{Code: code, Key: key, Kind: IssueMissing})
+			continue
+		}
+
+		expected, actual := formatArgs(value), formatArgs(other)
+		if !sameArgs(expected, actual) {
+			detail := fmt.Sprintf("expected %s, got %s", describeArgs(expected), describeArgs(actual))
+			issues = append(issues, LanguageIssue{Code: code, Key: key, Kind: IssueFormat, Detail: detail})
+		}
+	}
+
+	for key := range lang {
+		if _, ok := reference[key]; !ok {
+			issues = append(issues, LanguageIssue{Code: code, Key: key, Kind: IssueExtra})
+		}
+	}
+	return issues
+}
+
+// 检查所有语言包
+func checkLanguages(def string, packs map[string]privLanguges) ([]LanguageIssue, error) {
+	reference, ok := packs[def]
+	if !ok {
+		return nil, fmt.Errorf("reference language not found, %s", def)
+	}
+
+	codes := make([]string, 0, len(packs))
+	for code := range packs {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	issues := make([]LanguageIssue, 0)
+	for _, code := range codes {
+		if code != def {
+			issues = append(issues, checkLanguage(reference, packs[code])...)
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Code != issues[j].Code {
+			return issues[i].Code < issues[j].Code
+		}
+		return issues[i].Key < issues[j].Key
+	})
+	return issues, nil
+}
+
+// 筛选错误
+func languageErrors(issues []LanguageIssue) error {
+	errs := make(LanguageError, 0)
+	for _, issue := range issues {
+		if issue.IsError() {
+			errs = append(errs, issue)
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// 解码语言包
+func decodeLanguage(data []byte) (privLanguges, error) {
+	lang := make(privLanguges)
+	err := json.Unmarshal(data, &lang)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := lang["lng_language_code"]; !ok {
+		return nil, errors.New("not found lng_language_code")
+	}
+	return lang, nil
+}
+
+// 加载语言包目录
+// 返回语言代码到语言包和文件路径的映射
+func loadLanguageFiles(dir string) (map[string]privLanguges, map[string]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	packs := make(map[string]privLanguges)
+	paths := make(map[string]string)
+	for _, v := range files {
+		if v.IsDir() || strings.ToLower(filepath.Ext(v.Name())) != ".lang" {
+			continue
+		}
+
+		fullname := dir + string(filepath.Separator) + v.Name()
+		data, err := ioutil.ReadFile(fullname)
+		if err != nil {
+			return nil, nil, err
+		}
+		lang, err := decodeLanguage(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s, %v", fullname, err)
+		}
+
+		code := lang["lng_language_code"]
+		if path, ok := paths[code]; ok {
+			return nil, nil, fmt.Errorf("duplicate language code %s, %s and %s", code, path, fullname)
+		}
+		packs[code] = lang
+		paths[code] = fullname
+	}
+	return packs, paths, nil
+}
+
+// 检查语言包目录
+// 以参考语言检查目录下所有语言包，返回发现的问题
+func CheckLanguages(dir, reference string) ([]LanguageIssue, error) {
+	packs, _, err := loadLanguageFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return checkLanguages(reference, packs)
+}
@@ -1,58 +1,155 @@
 package config
 
 import (
-	"encoding/json"
-	"errors"
+	"sort"
+	"strings"
 	"sync"
 )
 
+// 语言信息
+type Language struct {
+	Code string // 语言代码
+	Name string // 语言名称
+}
+
 // 语言包配置
 type Languges struct {
 	lock sync.RWMutex
+	def  string
 	priv map[string]privLanguges
 }
 
 type privLanguges map[string]string
 
 // 创建语言包
-func NewLanguges() *Languges {
+func NewLanguges(def string) *Languges {
 	return &Languges{
+		def:  def,
 		priv: make(map[string]privLanguges),
 	}
 }
 
+// 默认语言
+func (l *Languges) Default() string {
+	return l.def
+}
+
 // 获取配置
+// 语言包缺少的配置项使用默认语言
 func (l *Languges) Value(code string, key string) string {
 	l.lock.RLock()
 	defer l.lock.RUnlock()
 
-	lang, ok := l.priv[code]
-	if !ok {
-		return ""
+	if lang, ok := l.priv[code]; ok {
+		if val, ok := lang[key]; ok {
+			return val
+		}
 	}
 
-	val, ok := lang[key]
+	lang, ok := l.priv[l.def]
 	if !ok {
 		return ""
 	}
-	return val
+	return lang[key]
+}
+
+// 语言是否存在
+func (l *Languges) Has(code string) bool {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	_, ok := l.priv[code]
+	return ok
+}
+
+// 获取语言列表
+func (l *Languges) Languages() []Language {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	languages := make([]Language, 0, len(l.priv))
+	for code, lang := range l.priv {
+		name, ok := lang["lng_language_name"]
+		if !ok {
+			name = code
+		}
+		languages = append(languages, Language{Code: code, Name: name})
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		return languages[i].Code < languages[j].Code
+	})
+	return languages
+}
+
+// 匹配语言
+// 将电报语言代码(如 en、zh-hans)匹配到语言包，失败返回默认语言
+func (l *Languges) Match(languageCode string) string {
+	if len(languageCode) == 0 {
+		return l.def
+	}
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	tag := strings.ToLower(strings.Replace(languageCode, "-", "_", -1))
+	for code := range l.priv {
+		if strings.ToLower(code) == tag {
+			return code
+		}
+	}
+
+	// 按主语言匹配，优先匹配默认语言
+	primary := strings.SplitN(tag, "_", 2)[0]
+	if strings.SplitN(strings.ToLower(l.def), "_", 2)[0] == primary {
+		return l.def
+	}
+	matched := ""
+	for code := range l.priv {
+		if strings.SplitN(strings.ToLower(code), "_", 2)[0] == primary {
+			if len(matched) == 0 || code < matched {
+				matched = code
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return l.def
+	}
+	return matched
+}
+
+// 获取生效语言
+// 优先使用用户选择的语言，未选择或语言包已移除时按客户端语言代码匹配
+func (l *Languges) Effective(language, languageCode string) string {
+	if len(language) > 0 && l.Has(language) {
+		return language
+	}
+	return l.Match(languageCode)
 }
 
 // 解析数据
+// 替换后的语言包存在错误时拒绝加载
 func (l *Languges) parse(data []byte) error {
-	lang := make(privLanguges)
-	err := json.Unmarshal(data, &lang)
+	lang, err := decodeLanguage(data)
 	if err != nil {
 		return err
 	}
 
-	code, ok := lang["lng_language_code"]
-	if !ok {
-		return errors.New("not found lng_language_code")
-	}
-
 	l.lock.Lock()
 	defer l.lock.Unlock()
+
+	code := lang["lng_language_code"]
+	packs := make(map[string]privLanguges, len(l.priv)+1)
+	for k, v := range l.priv {
+		packs[k] = v
+	}
+	packs[code] = lang
+	issues, err := checkLanguages(l.def, packs)
+	if err != nil {
+		return err
+	}
+	if err = languageErrors(issues); err != nil {
+		return err
+	}
+
 	l.priv[code] = lang
 	return nil
 }
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zhangpanyi/basebot/logger"
+	"gopkg.in/yaml.v2"
+)
+
+// 默认提现超时时间(秒)
+const DefaultWithdrawTimeout = 600
+
+// 配置变更处理器
+type ServeHandler func(old, new Serve)
+
+// 订阅配置变更
+// 服务配置重新加载后在观察协程中依次调用
+func Subscribe(handler ServeHandler) {
+	globalManager.lock.Lock()
+	defer globalManager.lock.Unlock()
+	globalManager.handlers = append(globalManager.handlers, handler)
+}
+
+// 解析服务配置
+// 环境变量优先于配置文件，检查失败时同时返回解析结果和所有错误
+func parseServe(data []byte) (*Serve, error) {
+	serve := Serve{WithdrawTimeout: DefaultWithdrawTimeout}
+	err := yaml.Unmarshal(data, &serve)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make(ConfigError, 0)
+	errs = append(errs, applyEnv(&serve, os.LookupEnv)...)
+	if len(serve.DefaultLanguage) == 0 {
+		serve.DefaultLanguage = "zh_CN"
+	}
+	errs = append(errs, serve.validate()...)
+	if len(errs) > 0 {
+		return &serve, errs
+	}
+	return &serve, nil
+}
+
+// 获取需要重启的变更字段
+// 这些字段只在启动时读取，运行中修改不会生效
+func (serve *Serve) restartFields(other *Serve) []string {
+	fields := make([]string, 0)
+	if serve.Host != other.Host {
+		fields = append(fields, "host")
+	}
+	if serve.Port != other.Port {
+		fields = append(fields, "port")
+	}
+	if serve.Token != other.Token {
+		fields = append(fields, "token")
+	}
+	if serve.APIAccess != other.APIAccess {
+		fields = append(fields, "api_access")
+	}
+	if serve.BolTDBPath != other.BolTDBPath {
+		fields = append(fields, "boltdb_path")
+	}
+	if serve.Languages != other.Languages {
+		fields = append(fields, "languages")
+	}
+	if serve.DefaultLanguage != other.DefaultLanguage {
+		fields = append(fields, "default_language")
+	}
+	if serve.Webhook != other.Webhook {
+		fields = append(fields, "webhook")
+	}
+	if serve.DispatchWorkers != other.DispatchWorkers {
+		fields = append(fields, "dispatch_workers")
+	}
+	if serve.DispatchQueueSize != other.DispatchQueueSize {
+		fields = append(fields, "dispatch_queue_size")
+	}
+	if serve.UnhealthyAfter != other.UnhealthyAfter {
+		fields = append(fields, "unhealthy_after")
+	}
+	if serve.BroadcastRate != other.BroadcastRate {
+		fields = append(fields, "broadcast_rate")
+	}
+
+	// 资产列表和精度关系到账户数据
+	changed := len(serve.Assets) != len(other.Assets)
+	for i := 0; !changed && i < len(serve.Assets); i++ {
+		changed = serve.Assets[i].Symbol != other.Assets[i].Symbol ||
+			serve.Assets[i].Precision != other.Assets[i].Precision
+	}
+	if changed {
+		fields = append(fields, "assets.symbol", "assets.precision")
+	}
+	return fields
+}
+
+// 服务配置解析器
+type serveParser struct {
+	manager *Manager
+}
+
+// 重新加载服务配置
+// 修改了需要重启的字段时拒绝加载，成功后通知订阅者
+func (p *serveParser) parse(data []byte) error {
+	serve, err := parseServe(data)
+	if err != nil {
+		return err
+	}
+
+	m := p.manager
+	m.lock.Lock()
+	old := m.serve.Load().(*Serve)
+	if fields := old.restartFields(serve); len(fields) > 0 {
+		m.lock.Unlock()
+		return fmt.Errorf("fields require restart: %s", strings.Join(fields, ", "))
+	}
+	m.serve.Store(serve)
+	handlers := make([]ServeHandler, len(m.handlers))
+	copy(handlers, m.handlers)
+	m.lock.Unlock()
+
+	for _, handler := range handlers {
+		notify(handler, *old, *serve)
+	}
+	return nil
+}
+
+// 通知配置变更
+func notify(handler ServeHandler, old, new Serve) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Errorf("Config notify: handler panic, %v", err)
+		}
+	}()
+	handler(old, new)
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 配置错误
+// 汇总检查配置时发现的所有错误
+type ConfigError []error
+
+func (e ConfigError) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	items := make([]string, 0, len(e))
+	for _, err := range e {
+		items = append(items, err.Error())
+	}
+	return fmt.Sprintf("%d config errors: %s", len(e), strings.Join(items, "; "))
+}
+
+// 检查服务配置
+func (serve *Serve) validate() ConfigError {
+	errs := make(ConfigError, 0)
+	if len(serve.Token) == 0 {
+		errs = append(errs, errors.New("token is empty"))
+	}
+	if serve.Port <= 0 || serve.Port > 65535 {
+		errs = append(errs, errors.New("invalid port"))
+	}
+	if len(serve.BolTDBPath) == 0 {
+		errs = append(errs, errors.New("boltdb path is empty"))
+	}
+	if len(serve.Languages) == 0 {
+		errs = append(errs, errors.New("languages path is empty"))
+	}
+	if serve.WithdrawTimeout == 0 && !serve.WithdrawNoTimeout {
+		errs = append(errs, errors.New("withdraw timeout is 0, set withdraw_no_timeout to wait forever"))
+	}
+	if serve.WithdrawRetries < 0 {
+		errs = append(errs, errors.New("invalid withdraw retries"))
+	}
+	if serve.MaxMessageLen < 0 {
+		errs = append(errs, errors.New("invalid max message len"))
+	}
+	if serve.MaxHistoryTextLen < 0 {
+		errs = append(errs, errors.New("invalid max history text len"))
+	}
+	if serve.DispatchWorkers < 0 {
+		errs = append(errs, errors.New("invalid dispatch workers"))
+	}
+	if serve.DispatchQueueSize < 0 {
+		errs = append(errs, errors.New("invalid dispatch queue size"))
+	}
+	if serve.BroadcastRate < 0 {
+		errs = append(errs, errors.New("invalid broadcast rate"))
+	}
+	errs = append(errs, serve.checkAssets()...)
+	errs = append(errs, serve.Webhook.check()...)
+	return errs
+}
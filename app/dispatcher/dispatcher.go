@@ -0,0 +1,169 @@
+package dispatcher
+
+import (
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+
+	"github.com/zhangpanyi/basebot/logger"
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"github.com/zhangpanyi/basebot/telegram/types"
+	"github.com/zhangpanyi/basebot/telegram/updater"
+)
+
+var once sync.Once
+var Dispatcher *UpdateDispatcher
+
+// 默认工作协程数量
+const DefaultWorkers = 64
+
+// 默认队列长度
+const DefaultQueueSize = 128
+
+// 分发统计
+type Stats struct {
+	Workers    int    `json:"workers"`     // 工作协程数量
+	QueueSize  int    `json:"queue_size"`  // 队列长度
+	Pending    int64  `json:"pending"`     // 排队数量
+	Running    int64  `json:"running"`     // 处理中数量
+	MaxPending int64  `json:"max_pending"` // 最大排队数量
+	MaxBacklog int    `json:"max_backlog"` // 当前最长队列
+	Dispatched uint64 `json:"dispatched"`  // 已分发数量
+	Completed  uint64 `json:"completed"`   // 已完成数量
+	Blocked    uint64 `json:"blocked"`     // 队列满阻塞次数
+	Panics     uint64 `json:"panics"`      // 处理异常次数
+}
+
+// 更新任务
+type task struct {
+	bot    *methods.BotExt
+	update *types.Update
+	done   func()
+}
+
+// 更新分发器
+// 按用户ID将更新分配到固定的工作队列，同一用户的更新按顺序处理，
+// 工作协程数量即为全局并发上限，队列满时阻塞调用方
+type UpdateDispatcher struct {
+	handler    updater.Handler
+	shards     []chan *task
+	pending    int64
+	running    int64
+	maxPending int64
+	dispatched uint64
+	completed  uint64
+	blocked    uint64
+	panics     uint64
+}
+
+// 创建分发器
+func NewDispatcherOnce(workers, queueSize int, handler updater.Handler) {
+	once.Do(func() {
+		if workers <= 0 {
+			workers = DefaultWorkers
+		}
+		if queueSize <= 0 {
+			queueSize = DefaultQueueSize
+		}
+		Dispatcher = &UpdateDispatcher{
+			handler: handler,
+			shards:  make([]chan *task, workers),
+		}
+		for i := 0; i < workers; i++ {
+			Dispatcher.shards[i] = make(chan *task, queueSize)
+			go Dispatcher.work(Dispatcher.shards[i])
+		}
+	})
+}
+
+// 分发更新
+func Dispatch(bot *methods.BotExt, update *types.Update, done func()) {
+	Dispatcher.Dispatch(bot, update, done)
+}
+
+// 分发更新
+// 处理完成后调用done，done可以为空，轮询模式下用于更新偏移跟踪器
+func (d *UpdateDispatcher) Dispatch(bot *methods.BotExt, update *types.Update, done func()) {
+	shard := d.shards[shardKey(update)%uint64(len(d.shards))]
+	atomic.AddUint64(&d.dispatched, 1)
+	pending := atomic.AddInt64(&d.pending, 1)
+	for {
+		max := atomic.LoadInt64(&d.maxPending)
+		if pending <= max || atomic.CompareAndSwapInt64(&d.maxPending, max, pending) {
+			break
+		}
+	}
+
+	t := &task{bot: bot, update: update, done: done}
+	select {
+	case shard <- t:
+	default:
+		atomic.AddUint64(&d.blocked, 1)
+		shard <- t
+	}
+}
+
+// 获取统计
+func (d *UpdateDispatcher) Stats() Stats {
+	backlog := 0
+	for _, shard := range d.shards {
+		if n := len(shard); n > backlog {
+			backlog = n
+		}
+	}
+	return Stats{
+		Workers:    len(d.shards),
+		QueueSize:  cap(d.shards[0]),
+		Pending:    atomic.LoadInt64(&d.pending),
+		Running:    atomic.LoadInt64(&d.running),
+		MaxPending: atomic.LoadInt64(&d.maxPending),
+		MaxBacklog: backlog,
+		Dispatched: atomic.LoadUint64(&d.dispatched),
+		Completed:  atomic.LoadUint64(&d.completed),
+		Blocked:    atomic.LoadUint64(&d.blocked),
+		Panics:     atomic.LoadUint64(&d.panics),
+	}
+}
+
+// 工作循环
+func (d *UpdateDispatcher) work(shard chan *task) {
+	for t := range shard {
+		atomic.AddInt64(&d.pending, -1)
+		atomic.AddInt64(&d.running, 1)
+		d.handle(t)
+		atomic.AddInt64(&d.running, -1)
+		atomic.AddUint64(&d.completed, 1)
+		if t.done != nil {
+			t.done()
+		}
+	}
+}
+
+// 处理更新
+func (d *UpdateDispatcher) handle(t *task) {
+	defer func() {
+		if err := recover(); err != nil {
+			atomic.AddUint64(&d.panics, 1)
+			logger.Errorf("Failed to handle update, update_id: %d, %v\n%s",
+				t.update.UpdateID, err, debug.Stack())
+		}
+	}()
+	d.handler(t.bot, t.update)
+}
+
+// 获取分片键
+// 使用发送者ID，无法识别发送者时使用会话ID
+func shardKey(update *types.Update) uint64 {
+	switch {
+	case update.Message != nil:
+		if update.Message.From != nil {
+			return uint64(update.Message.From.ID)
+		}
+		return uint64(update.Message.Chat.ID)
+	case update.CallbackQuery != nil:
+		return uint64(update.CallbackQuery.From.ID)
+	case update.InlineQuery != nil:
+		return uint64(update.InlineQuery.From.ID)
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package dispatcher
+
+import (
+	"sync"
+)
+
+// 偏移跟踪器
+// 记录已分发但未处理完成的更新，偏移推进到最小的未完成更新，
+// 慢用户只会阻止偏移越过自己的更新，不影响其他用户的更新处理
+type OffsetTracker struct {
+	lock    sync.Mutex
+	next    uint32              // 已分发的最大更新ID+1
+	pending map[uint32]struct{} // 未完成的更新ID
+}
+
+// 创建偏移跟踪器
+func NewOffsetTracker(offset uint32) *OffsetTracker {
+	return &OffsetTracker{
+		next:    offset,
+		pending: make(map[uint32]struct{}),
+	}
+}
+
+// 记录分发更新
+func (tracker *OffsetTracker) Track(updateID uint32) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	tracker.pending[updateID] = struct{}{}
+	if updateID >= tracker.next {
+		tracker.next = updateID + 1
+	}
+}
+
+// 标记更新完成
+func (tracker *OffsetTracker) Done(updateID uint32) {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	delete(tracker.pending, updateID)
+}
+
+// 获取连续完成偏移
+// 此偏移之前的更新都已处理完成
+func (tracker *OffsetTracker) Offset() uint32 {
+	tracker.lock.Lock()
+	defer tracker.lock.Unlock()
+	offset := tracker.next
+	for updateID := range tracker.pending {
+		if updateID < offset {
+			offset = updateID
+		}
+	}
+	return offset
+}
@@ -0,0 +1,204 @@
+package fmath
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// 最大精度
+const MaxPrecision = 36
+
+var (
+	// 无效金额
+	ErrInvalidDecimal = errors.New("invalid decimal")
+	// 精度溢出
+	ErrPrecisionOverflow = errors.New("precision overflow")
+)
+
+// 定点小数
+// 以最小单位的整数数量及精度表示金额，例如精度为4时，1.5表示为15000
+type Decimal struct {
+	value *big.Int // 最小单位数量
+	prec  int      // 小数精度
+}
+
+// 创建定点小数
+func NewDecimal(units *big.Int, prec int) Decimal {
+	return Decimal{value: new(big.Int).Set(units), prec: prec}
+}
+
+// 从整数创建
+func FromInt(x int64, prec int) Decimal {
+	value := new(big.Int).Mul(big.NewInt(x), pow10(prec))
+	return Decimal{value: value, prec: prec}
+}
+
+// 零值
+func Zero(prec int) Decimal {
+	return Decimal{value: new(big.Int), prec: prec}
+}
+
+// 10的n次方
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// 最小单位数量
+func (d Decimal) units() *big.Int {
+	if d.value == nil {
+		return new(big.Int)
+	}
+	return d.value
+}
+
+// 获取最小单位数量
+func (d Decimal) Units() *big.Int {
+	return new(big.Int).Set(d.units())
+}
+
+// 获取精度
+func (d Decimal) Prec() int {
+	return d.prec
+}
+
+// 调整精度(四舍五入)
+func (d Decimal) Round(prec int) Decimal {
+	if prec >= d.prec {
+		value := new(big.Int).Mul(d.units(), pow10(prec-d.prec))
+		return Decimal{value: value, prec: prec}
+	}
+
+	base := pow10(d.prec - prec)
+	half := new(big.Int).Quo(base, big.NewInt(2))
+	value := new(big.Int).Abs(d.units())
+	value.Add(value, half).Quo(value, base)
+	if d.units().Sign() < 0 {
+		value.Neg(value)
+	}
+	return Decimal{value: value, prec: prec}
+}
+
+// 对齐精度
+func align(x, y Decimal) (*big.Int, *big.Int, int) {
+	if x.prec == y.prec {
+		return x.units(), y.units(), x.prec
+	}
+	if x.prec > y.prec {
+		return x.units(), y.Round(x.prec).units(), x.prec
+	}
+	return x.Round(y.prec).units(), y.units(), y.prec
+}
+
+// 相加
+func (d Decimal) Add(y Decimal) Decimal {
+	a, b, prec := align(d, y)
+	return Decimal{value: new(big.Int).Add(a, b), prec: prec}
+}
+
+// 相减
+func (d Decimal) Sub(y Decimal) Decimal {
+	a, b, prec := align(d, y)
+	return Decimal{value: new(big.Int).Sub(a, b), prec: prec}
+}
+
+// 乘以整数
+func (d Decimal) Mul(n int64) Decimal {
+	return Decimal{value: new(big.Int).Mul(d.units(), big.NewInt(n)), prec: d.prec}
+}
+
+// 取反
+func (d Decimal) Neg() Decimal {
+	return Decimal{value: new(big.Int).Neg(d.units()), prec: d.prec}
+}
+
+// 取绝对值
+func (d Decimal) Abs() Decimal {
+	return Decimal{value: new(big.Int).Abs(d.units()), prec: d.prec}
+}
+
+// 比较大小
+func (d Decimal) Cmp(y Decimal) int {
+	a, b, _ := align(d, y)
+	return a.Cmp(b)
+}
+
+// 获取符号
+func (d Decimal) Sign() int {
+	return d.units().Sign()
+}
+
+// 是否为零
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// 转为字符串
+func (d Decimal) String() string {
+	value := d.units()
+	if d.prec <= 0 {
+		return value.String()
+	}
+
+	neg := value.Sign() < 0
+	digits := new(big.Int).Abs(value).String()
+	if len(digits) <= d.prec {
+		digits = strings.Repeat("0", d.prec-len(digits)+1) + digits
+	}
+	integer := digits[:len(digits)-d.prec]
+	fraction := strings.TrimRight(digits[len(digits)-d.prec:], "0")
+
+	s := integer
+	if len(fraction) > 0 {
+		s += "." + fraction
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// 序列化JSON
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + d.String() + `"`), nil
+}
+
+// 反序列化JSON
+// 兼容big.Float序列化的字符串，精度根据实际小数位数推断
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	value, err := parseRat(string(bytes.Trim(data, `"`)))
+	if err != nil {
+		return err
+	}
+	*d = *value
+	return nil
+}
+
+// 解析任意格式的小数
+func parseRat(s string) (*Decimal, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, ErrInvalidDecimal
+	}
+
+	// 推断小数精度
+	prec := 0
+	denom := r.Denom()
+	base := big.NewInt(1)
+	for new(big.Int).Mod(base, denom).Sign() != 0 {
+		prec++
+		if prec > MaxPrecision {
+			return nil, ErrPrecisionOverflow
+		}
+		base.Mul(base, big.NewInt(10))
+	}
+
+	value := new(big.Int).Mul(r.Num(), base)
+	value.Quo(value, denom)
+	return &Decimal{value: value, prec: prec}, nil
+}
@@ -2,35 +2,72 @@ package fmath
 
 import (
 	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
 )
 
-var prec uint
+// 匹配金额
+var reAmount = regexp.MustCompile("^[0-9]+(\\.[0-9]*)?$")
 
-func init() {
-	prec = big.NewFloat(0).Prec()
-}
+// 解析金额
+func Parse(s string, precision int) (Decimal, bool) {
+	if !reAmount.MatchString(s) {
+		return Decimal{}, false
+	}
 
-// 精度
-func Prec() uint {
-	return prec
-}
+	// 检查小数位数
+	integer, fraction := s, ""
+	if idx := strings.Index(s, "."); idx != -1 {
+		integer, fraction = s[:idx], s[idx+1:]
+	}
+	if len(fraction) > precision {
+		return Decimal{}, false
+	}
 
-// 相加
-func Add(x *big.Float, y *big.Float) *big.Float {
-	return big.NewFloat(0).Add(x, y)
+	// 转换为最小单位
+	digits := integer + fraction + strings.Repeat("0", precision-len(fraction))
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, false
+	}
+	return Decimal{value: value, prec: precision}, true
 }
 
-// 相减
-func Sub(x *big.Float, y *big.Float) *big.Float {
-	return big.NewFloat(0).Sub(x, y)
+// 解析浮点数
+func FromFloat(f float64, precision int) (Decimal, bool) {
+	if f < 0 {
+		return Decimal{}, false
+	}
+	return Parse(strconv.FormatFloat(f, 'f', -1, 64), precision)
 }
 
-// 相乘
-func Mul(x *big.Float, y *big.Float) *big.Float {
-	return big.NewFloat(0).Mul(x, y)
+// 解析任意格式的小数，并按精度四舍五入
+func ParseRound(s string, precision int) (Decimal, bool) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Decimal{}, false
+	}
+
+	// 放大后四舍五入
+	num := new(big.Int).Mul(r.Num(), pow10(precision))
+	denom := r.Denom()
+	value, rem := new(big.Int).QuoRem(num, denom, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(denom) >= 0 {
+		if num.Sign() < 0 {
+			value.Sub(value, big.NewInt(1))
+		} else {
+			value.Add(value, big.NewInt(1))
+		}
+	}
+	return Decimal{value: value, prec: precision}, true
 }
 
-// 取绝对值
-func Abs(x *big.Float) *big.Float {
-	return big.NewFloat(0).Abs(x)
+// 求和
+func Sum(precision int, values ...Decimal) Decimal {
+	sum := Zero(precision)
+	for _, value := range values {
+		sum = sum.Add(value)
+	}
+	return sum
 }
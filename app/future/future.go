@@ -1,5 +1,16 @@
 package future
 
+import (
+	"errors"
+	"time"
+)
+
+// 等待超时
+var ErrTimeout = errors.New("future timeout")
+
+// 已经移除
+var ErrCanceled = errors.New("future canceled")
+
 // Future
 type Future struct {
 	id string
@@ -13,8 +24,9 @@ type result struct {
 }
 
 // 创建Future
+// 通道带有缓冲，设置结果时不会阻塞脚本
 func newFuture(id string) *Future {
-	ch := make(chan result)
+	ch := make(chan result, 1)
 	return &Future{ch: ch, id: id}
 }
 
@@ -24,12 +36,30 @@ func (f *Future) ID() string {
 }
 
 // 获取结果
-func (f *Future) GetResult() (string, error) {
-	r := <-f.ch
-	return r.txid, r.err
+// 超时时间为0表示一直等待
+func (f *Future) GetResult(timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		r := <-f.ch
+		return r.txid, r.err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-f.ch:
+		return r.txid, r.err
+	case <-timer.C:
+		return "", ErrTimeout
+	}
 }
 
 // 设置结果
-func (f *Future) SetResult(txid string, err error) {
-	f.ch <- result{txid: txid, err: err}
+// 只保留第一次设置的结果
+func (f *Future) SetResult(txid string, err error) bool {
+	select {
+	case f.ch <- result{txid: txid, err: err}:
+		return true
+	default:
+		return false
+	}
 }
@@ -16,10 +16,14 @@ type FutureManager struct {
 }
 
 // 创建Future
-func (m *FutureManager) NewFuture() *Future {
-	token := make([]byte, 8)
-	rand.Read(token)
-	future := newFuture(hex.EncodeToString(token))
+// ID为空时随机生成
+func (m *FutureManager) NewFuture(id string) *Future {
+	if id == "" {
+		token := make([]byte, 8)
+		rand.Read(token)
+		id = hex.EncodeToString(token)
+	}
+	future := newFuture(id)
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	m.futures[future.id] = future
@@ -29,13 +33,30 @@ func (m *FutureManager) NewFuture() *Future {
 // 设置结果
 func (m *FutureManager) SetResult(id, txid string, err error) {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
 	future, ok := m.futures[id]
-	if !ok {
-		return
+	if ok {
+		delete(m.futures, id)
+	}
+	m.mutex.Unlock()
+
+	if ok {
+		future.SetResult(txid, err)
+	}
+}
+
+// 移除Future
+// 不再需要结果时调用，等待中的调用返回ErrCanceled，之后设置的结果将被丢弃
+func (m *FutureManager) Remove(id string) {
+	m.mutex.Lock()
+	future, ok := m.futures[id]
+	if ok {
+		delete(m.futures, id)
+	}
+	m.mutex.Unlock()
+
+	if ok {
+		future.SetResult("", ErrCanceled)
 	}
-	future.SetResult(txid, err)
-	delete(m.futures, id)
 }
 
 // 创建Future管理器
@@ -5,6 +5,8 @@ import (
 	"math/big"
 	"math/rand"
 	"time"
+
+	"luckybot/app/fmath"
 )
 
 var (
@@ -15,26 +17,16 @@ var (
 )
 
 // 生成算法
-func Generate(amount *big.Float, precision, number int) ([]*big.Float, error) {
-	if precision < 0 {
-		precision = 0
-	}
-
-	base := big.NewInt(10)
-	base.Exp(base, big.NewInt(int64(precision)), nil)
-	wei, _ := big.NewFloat(0).SetString(base.String())
-
-	product := big.NewFloat(0).Mul(wei, amount)
-	newAmount, _ := product.Int(big.NewInt(0))
-	arr, err := generate(newAmount, number)
+// 按金额的最小单位进行拆分，结果与金额精度相同
+func Generate(amount fmath.Decimal, number int) ([]fmath.Decimal, error) {
+	arr, err := generate(amount.Units(), number)
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]*big.Float, 0, number)
+	result := make([]fmath.Decimal, 0, number)
 	for i := 0; i < len(arr); i++ {
-		tmp := big.NewFloat(0).SetInt(arr[i])
-		result = append(result, tmp.Quo(tmp, wei))
+		result = append(result, fmath.NewDecimal(arr[i], amount.Prec()))
 	}
 	return result, nil
 }
@@ -76,13 +68,9 @@ func generateResultSet(amount *big.Int, number int) ([]*big.Int, error) {
 	result := make([]*big.Int, 0, number)
 	for i := 1; i < number; i++ {
 		value := big.NewInt(1)
-		x, ok := big.NewFloat(0).SetString(
-			subBigInt(amount, big.NewInt(int64(number-1))).String())
-		if !ok {
-			return nil, errors.New("invalid number")
-		}
-		y := big.NewFloat(float64(number - i))
-		safeAmount, _ := big.NewFloat(0).Quo(x, y).Int(big.NewInt(0))
+		x := subBigInt(amount, big.NewInt(int64(number-1)))
+		y := big.NewInt(int64(number - i))
+		safeAmount := big.NewInt(0).Quo(x, y)
 		if safeAmount.Cmp(one) == 1 {
 			value.Add(one, big.NewInt(0).Rand(randx, safeAmount.Sub(safeAmount, one)))
 		}
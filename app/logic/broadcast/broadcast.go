@@ -0,0 +1,253 @@
+package broadcast
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zhangpanyi/basebot/logger"
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"luckybot/app/logic/botext"
+	"luckybot/app/logic/pusher"
+	"luckybot/app/storage/models"
+)
+
+var once sync.Once
+var engine *broadcastEngine
+
+// 默认发送速率
+const DefaultRate = 25
+
+// 每批发送数量
+const chunkSize = 50
+
+// 限流最大重试次数
+const maxRetries = 3
+
+// 运行广播服务
+// rate为每秒最多发送的消息数量
+func ServiceStart(rate int) {
+	once.Do(func() {
+		if rate <= 0 {
+			rate = DefaultRate
+		}
+		engine = &broadcastEngine{
+			limiter: newTokenBucket(rate),
+			wakeup:  make(chan struct{}, 1),
+		}
+		go engine.loop()
+		go engine.scheduleLoop()
+	})
+}
+
+// 创建广播
+// 向满足受众条件的订户发送消息，segment为空时发送给所有活跃订户
+func Create(content *models.BroadcastContent, segment *models.Segment) (*models.Broadcast, error) {
+	if err := checkContent(content); err != nil {
+		return nil, err
+	}
+	if len(content.FileID) > 0 {
+		content.File = nil
+	}
+	if len(content.File) > 0 && len(content.FileName) == 0 {
+		content.FileName = defaultFileName(content.Media)
+	}
+
+	receivers, err := Audience(segment)
+	if err != nil {
+		return nil, err
+	}
+
+	model := models.BroadcastModel{}
+	broadcast, err := model.NewBroadcast(&models.Broadcast{
+		BroadcastContent: *content,
+		Segment:          segment,
+	}, receivers)
+	if err != nil {
+		return nil, err
+	}
+	logger.Warnf("Broadcast created, id: %d, total: %d", broadcast.ID, broadcast.Total)
+
+	notify()
+	return broadcast, nil
+}
+
+// 暂停广播
+func Pause(id uint64) (*models.Broadcast, error) {
+	model := models.BroadcastModel{}
+	return model.SetState(id, models.BroadcastPaused)
+}
+
+// 恢复广播
+func Resume(id uint64) (*models.Broadcast, error) {
+	model := models.BroadcastModel{}
+	broadcast, err := model.SetState(id, models.BroadcastRunning)
+	if err != nil {
+		return nil, err
+	}
+	notify()
+	return broadcast, nil
+}
+
+// 取消广播
+func Cancel(id uint64) (*models.Broadcast, error) {
+	model := models.BroadcastModel{}
+	return model.SetState(id, models.BroadcastCancelled)
+}
+
+// 唤醒广播
+func notify() {
+	if engine == nil {
+		return
+	}
+	select {
+	case engine.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// 广播引擎
+// 任务按创建顺序逐个发送，每批发送完成后保存进度，服务重启后从进度处继续
+type broadcastEngine struct {
+	limiter *tokenBucket
+	wakeup  chan struct{}
+}
+
+// 事件循环
+func (e *broadcastEngine) loop() {
+	model := models.BroadcastModel{}
+	for {
+		bot := botext.GetBot()
+		broadcast, err := model.GetRunning()
+		if err != nil {
+			logger.Warnf("Failed to get running broadcast, %v", err)
+		}
+		if bot == nil || broadcast == nil {
+			select {
+			case <-e.wakeup:
+			case <-time.After(time.Second * 5):
+			}
+			continue
+		}
+		e.run(bot, broadcast.ID)
+	}
+}
+
+// 执行广播
+func (e *broadcastEngine) run(bot *methods.BotExt, id uint64) {
+	model := models.BroadcastModel{}
+	for {
+		// 检查任务状态
+		broadcast, err := model.GetBroadcast(id)
+		if err != nil {
+			logger.Warnf("Failed to get broadcast, id: %d, %v", id, err)
+			return
+		}
+		if broadcast.State != models.BroadcastRunning {
+			logger.Infof("Broadcast stopped, id: %d, state: %d", id, broadcast.State)
+			return
+		}
+
+		// 获取接收者
+		receivers, err := model.GetReceivers(id, broadcast.Cursor, chunkSize)
+		if err != nil {
+			logger.Warnf("Failed to get broadcast receivers, id: %d, %v", id, err)
+			return
+		}
+
+		// 统计发送结果
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		progress := models.BroadcastProgress{Cursor: broadcast.Cursor + len(receivers)}
+		record := func(receiver int64, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			switch {
+			case err == nil:
+				progress.Sent++
+			case pusher.IsBlocked(err):
+				progress.Blocked++
+				subscriberModel := models.SubscriberModel{}
+				if setErr := subscriberModel.SetBlocked(receiver, true); setErr != nil {
+					logger.Warnf("Failed to set subscriber blocked, user: %d, %v", receiver, setErr)
+				}
+			default:
+				progress.Failed++
+				logger.Infof("Failed to send broadcast, id: %d, receiver: %d, %v", id, receiver, err)
+			}
+		}
+
+		// 发送消息
+		// 需要上传文件时逐个发送，直到获得文件ID
+		content := &broadcast.BroadcastContent
+		for _, receiver := range receivers {
+			e.limiter.Wait()
+			if len(content.File) > 0 {
+				fileID, err := e.upload(bot, content, receiver)
+				record(receiver, err)
+				if err == nil {
+					if _, err = model.SetFileID(id, fileID); err != nil {
+						logger.Warnf("Failed to set broadcast file id, id: %d, %v", id, err)
+					}
+					content.FileID = fileID
+					content.File = nil
+				}
+				continue
+			}
+
+			wg.Add(1)
+			go func(receiver int64) {
+				defer wg.Done()
+				record(receiver, e.send(bot, content, receiver))
+			}(receiver)
+		}
+		wg.Wait()
+
+		// 保存发送进度
+		broadcast, err = model.UpdateProgress(id, &progress)
+		if err != nil {
+			logger.Warnf("Failed to update broadcast progress, id: %d, %v", id, err)
+			return
+		}
+		if broadcast.State == models.BroadcastFinished {
+			logger.Warnf("Broadcast finished, id: %d, total: %d, sent: %d, failed: %d, blocked: %d",
+				id, broadcast.Total, broadcast.Sent, broadcast.Failed, broadcast.Blocked)
+			return
+		}
+	}
+}
+
+// 发送消息
+// 被限流时等待后重试
+func (e *broadcastEngine) send(bot *methods.BotExt, content *models.BroadcastContent, receiver int64) error {
+	msg := content.PushMessage(receiver)
+	for i := 0; ; i++ {
+		err := pusher.Deliver(bot, msg)
+		if err == nil || i >= maxRetries {
+			return err
+		}
+		retryAfter := pusher.RetryAfter(err)
+		if retryAfter == 0 {
+			return err
+		}
+		time.Sleep(retryAfter)
+		e.limiter.Wait()
+	}
+}
+
+// 上传文件
+// 被限流时等待后重试
+func (e *broadcastEngine) upload(bot *methods.BotExt, content *models.BroadcastContent, receiver int64) (string, error) {
+	msg := content.PushMessage(receiver)
+	for i := 0; ; i++ {
+		fileID, err := pusher.DeliverFile(bot, msg, content.File, content.FileName)
+		if err == nil || i >= maxRetries {
+			return fileID, err
+		}
+		retryAfter := pusher.RetryAfter(err)
+		if retryAfter == 0 {
+			return "", err
+		}
+		time.Sleep(retryAfter)
+		e.limiter.Wait()
+	}
+}
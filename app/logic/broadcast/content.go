@@ -0,0 +1,98 @@
+package broadcast
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"luckybot/app/storage/models"
+)
+
+// 最大消息长度
+const maxMessageLen = 4096
+
+// 最大标题长度
+const maxCaptionLen = 1024
+
+// 最大上传文件
+const maxFileSize = 10 << 20
+
+// 最大回调数据长度
+const maxCallbackDataLen = 64
+
+var (
+	// 消息内容为空
+	ErrEmptyMessage = errors.New("message is empty")
+	// 消息内容过长
+	ErrMessageTooLong = errors.New("message too long")
+	// 解析模式无效
+	ErrInvalidParseMode = errors.New("invalid parse mode")
+	// 媒体类型无效
+	ErrInvalidMedia = errors.New("invalid media")
+	// 缺少媒体文件
+	ErrMissingFile = errors.New("missing media file")
+	// 上传文件过大
+	ErrFileTooLarge = errors.New("file too large")
+	// 按钮无效
+	ErrInvalidButton = errors.New("invalid button")
+)
+
+// 检查广播内容
+func checkContent(content *models.BroadcastContent) error {
+	// 检查解析模式
+	switch content.ParseMode {
+	case "", models.ParseModeNone, methods.ParseModeMarkdown, methods.ParseModeHTML:
+	default:
+		return ErrInvalidParseMode
+	}
+
+	// 检查消息内容
+	length := utf8.RuneCountInString(content.Message)
+	switch content.Media {
+	case "":
+		if length == 0 {
+			return ErrEmptyMessage
+		}
+		if length > maxMessageLen {
+			return ErrMessageTooLong
+		}
+	case models.MediaPhoto, models.MediaDocument:
+		if length > maxCaptionLen {
+			return ErrMessageTooLong
+		}
+		if len(content.FileID) == 0 && len(content.File) == 0 {
+			return ErrMissingFile
+		}
+		if len(content.File) > maxFileSize {
+			return ErrFileTooLarge
+		}
+	default:
+		return ErrInvalidMedia
+	}
+
+	// 检查内联键盘
+	if content.Markup != nil {
+		for _, row := range content.Markup.InlineKeyboard {
+			for _, button := range row {
+				if button == nil || len(button.Text) == 0 {
+					return ErrInvalidButton
+				}
+				if (len(button.URL) > 0) == (len(button.CallbackData) > 0) {
+					return ErrInvalidButton
+				}
+				if len(button.CallbackData) > maxCallbackDataLen {
+					return ErrInvalidButton
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 默认上传文件名
+func defaultFileName(media string) string {
+	if media == models.MediaPhoto {
+		return "photo.jpeg"
+	}
+	return "document"
+}
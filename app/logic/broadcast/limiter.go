@@ -0,0 +1,48 @@
+package broadcast
+
+import (
+	"sync"
+	"time"
+)
+
+// 令牌桶
+type tokenBucket struct {
+	mutex    sync.Mutex
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// 创建令牌桶
+// rate为每秒生成的令牌数量，桶容量等于rate
+func newTokenBucket(rate int) *tokenBucket {
+	return &tokenBucket{
+		rate:     float64(rate),
+		capacity: float64(rate),
+		tokens:   float64(rate),
+		last:     time.Now(),
+	}
+}
+
+// 获取令牌
+// 没有可用令牌时阻塞等待
+func (b *tokenBucket) Wait() {
+	for {
+		b.mutex.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mutex.Unlock()
+			return
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mutex.Unlock()
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,99 @@
+package broadcast
+
+import (
+	"errors"
+	"time"
+
+	"github.com/zhangpanyi/basebot/logger"
+	"luckybot/app/storage/models"
+)
+
+// 最小重复间隔
+const minScheduleInterval = 60
+
+var (
+	// 执行时间无效
+	ErrInvalidRunAt = errors.New("run time must be in the future")
+	// 重复间隔无效
+	ErrInvalidInterval = errors.New("invalid schedule interval")
+)
+
+// 创建定时广播
+// interval大于0时按间隔重复执行
+func Schedule(content *models.BroadcastContent, segment *models.Segment,
+	runAt int64, interval uint32) (*models.BroadcastSchedule, error) {
+
+	if runAt <= time.Now().UTC().Unix() {
+		return nil, ErrInvalidRunAt
+	}
+	if interval > 0 && interval < minScheduleInterval {
+		return nil, ErrInvalidInterval
+	}
+	if err := checkContent(content); err != nil {
+		return nil, err
+	}
+	if segment != nil {
+		if err := checkSegment(segment); err != nil {
+			return nil, err
+		}
+	}
+
+	model := models.BroadcastScheduleModel{}
+	schedule, err := model.NewSchedule(&models.BroadcastSchedule{
+		BroadcastContent: *content,
+		Segment:          segment,
+		RunAt:            runAt,
+		Interval:         interval,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Warnf("Broadcast scheduled, id: %d, run_at: %d, interval: %d",
+		schedule.ID, schedule.RunAt, schedule.Interval)
+	return schedule, nil
+}
+
+// 取消定时广播
+func CancelSchedule(id uint64) (*models.BroadcastSchedule, error) {
+	model := models.BroadcastScheduleModel{}
+	return model.Cancel(id)
+}
+
+// 定时器循环
+func (e *broadcastEngine) scheduleLoop() {
+	tickTimer := time.NewTimer(time.Second)
+	for {
+		select {
+		case <-tickTimer.C:
+			e.handleSchedules()
+			tickTimer.Reset(time.Second)
+		}
+	}
+}
+
+// 执行到期定时广播
+func (e *broadcastEngine) handleSchedules() {
+	model := models.BroadcastScheduleModel{}
+	schedules, err := model.GetDue(time.Now().UTC().Unix())
+	if err != nil {
+		logger.Warnf("Failed to get due schedules, %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		reason := ""
+		var jobID uint64
+		content := schedule.BroadcastContent
+		job, err := Create(&content, schedule.Segment)
+		if err != nil {
+			reason = err.Error()
+			logger.Warnf("Failed to run scheduled broadcast, id: %d, %v", schedule.ID, err)
+		} else {
+			jobID = job.ID
+		}
+
+		if _, err = model.MarkRun(schedule.ID, jobID, reason); err != nil {
+			logger.Warnf("Failed to mark schedule run, id: %d, %v", schedule.ID, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package broadcast
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"luckybot/app/config"
+	"luckybot/app/storage"
+	"luckybot/app/storage/models"
+)
+
+var (
+	// 资产不存在
+	ErrUnknownSymbol = errors.New("unknown symbol")
+	// 受众条件无效
+	ErrInvalidSegment = errors.New("invalid segment")
+)
+
+// 获取受众
+// 从订户中筛选满足条件的用户，已屏蔽机器人的用户除外
+func Audience(segment *models.Segment) ([]int64, error) {
+	if segment == nil {
+		segment = &models.Segment{}
+	}
+	if err := checkSegment(segment); err != nil {
+		return nil, err
+	}
+
+	subscriberModel := models.SubscriberModel{}
+	subscribers, err := subscriberModel.GetSubscriberRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	// 指定用户列表
+	var include map[int64]bool
+	if len(segment.UserIDs) > 0 {
+		include = make(map[int64]bool, len(segment.UserIDs))
+		for _, userID := range segment.UserIDs {
+			include[userID] = true
+		}
+	}
+
+	receivers := make([]int64, 0)
+	for _, subscriber := range subscribers {
+		if subscriber.Blocked {
+			continue
+		}
+		if include != nil && !include[subscriber.UserID] {
+			continue
+		}
+		ok, err := match(segment, subscriber)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			receivers = append(receivers, subscriber.UserID)
+		}
+	}
+	return receivers, nil
+}
+
+// 检查受众条件
+func checkSegment(segment *models.Segment) error {
+	if segment.ActiveDays < 0 {
+		return ErrInvalidSegment
+	}
+	if segment.MinBalance != nil {
+		serveCfg := config.GetServe()
+		if _, ok := serveCfg.GetAsset(segment.Symbol); !ok {
+			return ErrUnknownSymbol
+		}
+	}
+	return nil
+}
+
+// 是否满足条件
+func match(segment *models.Segment, subscriber *models.Subscriber) (bool, error) {
+	// 匹配语言
+	// 使用用户实际生效的语言包代码，与语言切换保持一致
+	if len(segment.Languages) > 0 {
+		matched := false
+		effective := config.GetLanguge().Effective(subscriber.Language, subscriber.LanguageCode)
+		for _, language := range segment.Languages {
+			if strings.EqualFold(strings.Replace(language, "-", "_", -1), effective) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	// 匹配余额
+	if segment.MinBalance != nil {
+		model := models.AccountModel{}
+		account, err := model.GetAccount(subscriber.UserID, segment.Symbol)
+		if err != nil {
+			if err == models.ErrNoSuchTypeAccount || err == storage.ErrNoBucket {
+				return false, nil
+			}
+			return false, err
+		}
+		if account.Amount.Cmp(*segment.MinBalance) <= 0 {
+			return false, nil
+		}
+	}
+
+	// 匹配红包活跃
+	if segment.ActiveDays > 0 {
+		since := time.Now().UTC().AddDate(0, 0, -segment.ActiveDays).Unix()
+		model := models.AccountVersionModel{}
+		active, err := model.HasReasonSince(subscriber.UserID, since,
+			models.ReasonGive, models.ReasonReceive)
+		if err != nil || !active {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -4,11 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math/big"
 	"net/http"
 	"strconv"
 
 	"github.com/zhangpanyi/basebot/logger"
+	"luckybot/app/config"
+	"luckybot/app/fmath"
 	"luckybot/app/logic/handlers/utils"
 	"luckybot/app/logic/pusher"
 	"luckybot/app/logic/scriptengine"
@@ -73,8 +74,18 @@ func HandleDeposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 获取资产配置
+	serveCfg := config.GetServe()
+	asset, ok := serveCfg.GetAsset(request.Asset)
+	if !ok {
+		logger.Infof("Failed to deposit, unknown asset, asset: %s", request.Asset)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(makeErrorRespone("unknown asset"))
+		return
+	}
+
 	// 获取充值金额
-	amount, ok := big.NewFloat(0).SetString(request.Amount)
+	amount, ok := fmath.Parse(request.Amount, asset.Precision)
 	if !ok {
 		logger.Infof("Failed to deposit, amount invalid, amount: %s", request.Amount)
 		w.WriteHeader(http.StatusBadRequest)
@@ -91,19 +102,15 @@ func HandleDeposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 防止重复充值
-	if err = depositModel.Add(request.TxID, jsb); err != nil {
-		logger.Warnf("Failed to deposit, txid: %s, from: %s, to: %s, asset: %s, amount: %s, memo: %s, %v",
-			request.TxID, request.From, request.To, request.Asset, request.Amount, request.Memo, err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(makeErrorRespone("repeat deposit"))
-		return
-	}
-
 	// 增加用户资产
-	model := models.AccountModel{}
-	account, err := model.Deposit(userID, request.Asset, amount)
-	if err != nil {
+	version := &models.Version{
+		Balance:        &amount,
+		Reason:         models.ReasonDeposit,
+		RefTxID:        &request.TxID,
+		RefBlockHeight: &request.Height,
+	}
+	journal := models.NewJournal(request.Asset).Deposit(userID, amount).Record(userID, version)
+	if _, err = depositModel.AddAndPost(request.TxID, jsb, journal); err != nil {
 		logger.Warnf("Failed to deposit, txid: %s, from: %s, to: %s, asset: %s, amount: %s, memo: %s, %v",
 			request.TxID, request.From, request.To, request.Asset, request.Amount, request.Memo, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -111,21 +118,8 @@ func HandleDeposit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 写入充值记录
-	versionModel := models.AccountVersionModel{}
-	version, err := versionModel.InsertVersion(userID, &models.Version{
-		Symbol:         request.Asset,
-		Balance:        amount,
-		Amount:         account.Amount,
-		Reason:         models.ReasonDeposit,
-		RefTxID:        &request.TxID,
-		RefBlockHeight: &request.Height,
-	})
-
 	// 推送充值通知
-	if err == nil {
-		pusher.Post(userID, utils.MakeHistoryMessage(userID, version), true, nil)
-	}
+	pusher.Post(userID, utils.MakeHistoryMessage(userID, version), true, nil)
 	logger.Warnf("Deposit success, txid: %s, from: %s, to: %s, asset: %s, amount: %s, memo: %s",
 		request.TxID, request.From, request.To, request.Asset, request.Amount, request.Memo)
 
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zhangpanyi/basebot/history"
+	"github.com/zhangpanyi/basebot/logger"
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"github.com/zhangpanyi/basebot/telegram/types"
+	"luckybot/app/config"
+	"luckybot/app/logic/scriptengine"
+	"luckybot/app/storage/models"
+)
+
+// 最大地址数量
+const MaxAddressBookSize = 10
+
+// 最大标签长度
+const MaxAddressLabelLen = 16
+
+// 匹配地址
+var reMathAddress *regexp.Regexp
+
+// 匹配删除地址
+var reMathAddressRemove *regexp.Regexp
+
+// 匹配添加地址
+var reMathAddressAdd *regexp.Regexp
+
+func init() {
+	var err error
+	reMathAddress, err = regexp.Compile("^/address/(\\d+)/$")
+	if err != nil {
+		panic(err)
+	}
+
+	reMathAddressRemove, err = regexp.Compile("^/address/(\\d+)/remove/$")
+	if err != nil {
+		panic(err)
+	}
+
+	reMathAddressAdd, err = regexp.Compile("^/address/add/(\\w+)/$")
+	if err != nil {
+		panic(err)
+	}
+}
+
+// 地址簿
+type AddressBookHandler struct {
+}
+
+// 消息处理
+func (handler *AddressBookHandler) Handle(bot *methods.BotExt, r *history.History, update *types.Update) {
+	// 回复地址列表
+	data := update.CallbackQuery.Data
+	if data == "/address/" {
+		r.Clear()
+		bot.AnswerCallbackQuery(update.CallbackQuery, "", false, "", 0)
+		handler.replyAddressBook(bot, update.CallbackQuery, true)
+		return
+	}
+
+	// 回复选择资产
+	if data == "/address/add/" {
+		r.Clear()
+		handler.replyChooseAsset(bot, update.CallbackQuery)
+		return
+	}
+
+	// 回复添加地址
+	result := reMathAddressAdd.FindStringSubmatch(data)
+	if len(result) == 2 {
+		serveCfg := config.GetServe()
+		asset, ok := serveCfg.GetAsset(result[1])
+		if !ok {
+			return
+		}
+		handler.replyAddAddress(bot, r, update, asset)
+		return
+	}
+
+	// 处理删除地址
+	result = reMathAddressRemove.FindStringSubmatch(data)
+	if len(result) == 2 {
+		id, err := strconv.ParseUint(result[1], 10, 64)
+		if err != nil {
+			return
+		}
+		handler.handleRemoveAddress(bot, id, update.CallbackQuery)
+		return
+	}
+
+	// 回复地址详情
+	result = reMathAddress.FindStringSubmatch(data)
+	if len(result) == 2 {
+		id, err := strconv.ParseUint(result[1], 10, 64)
+		if err != nil {
+			return
+		}
+		handler.replyAddressDetail(bot, id, update.CallbackQuery)
+		return
+	}
+}
+
+// 消息路由
+func (handler *AddressBookHandler) route(bot *methods.BotExt, query *types.CallbackQuery) Handler {
+	return nil
+}
+
+// 回复地址列表
+func (handler *AddressBookHandler) replyAddressBook(bot *methods.BotExt, query *types.CallbackQuery, edit bool) {
+	// 获取地址列表
+	fromID := query.From.ID
+	model := models.AddressBookModel{}
+	entries, err := model.GetAddresses(fromID, "")
+	if err != nil {
+		logger.Warnf("Failed to get address book, user: %d, %v", fromID, err)
+	}
+
+	// 生成回复内容
+	lines := make([]string, 0, len(entries))
+	menus := make([]methods.InlineKeyboardButton, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, makeAddressEntryMessage(fromID, entry))
+		menus = append(menus, methods.InlineKeyboardButton{
+			Text:         entry.Label,
+			CallbackData: fmt.Sprintf("/address/%d/", entry.ID),
+		})
+	}
+	content := tr(fromID, "lng_address_book_empty")
+	if len(lines) > 0 {
+		content = strings.Join(lines, "\n\n")
+	}
+	reply := fmt.Sprintf(tr(fromID, "lng_address_book_say"), content)
+
+	// 生成菜单列表
+	baseMenus := [...]methods.InlineKeyboardButton{
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_address_add"),
+			CallbackData: "/address/add/",
+		},
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_back_menu"),
+			CallbackData: "/main/",
+		},
+	}
+	markup := methods.MakeInlineKeyboardMarkupAuto(menus, 2)
+	markup = markup.Merge(methods.MakeInlineKeyboardMarkupAuto(baseMenus[:], 1))
+
+	if !edit {
+		bot.SendMessage(fromID, reply, true, markup)
+	} else {
+		bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
+	}
+}
+
+// 回复选择资产
+func (handler *AddressBookHandler) replyChooseAsset(bot *methods.BotExt, query *types.CallbackQuery) {
+	fromID := query.From.ID
+	markup := makeAssetMenus(fromID, query.Data, "/address/")
+	bot.AnswerCallbackQuery(query, "", false, "", 0)
+	bot.EditMessageReplyMarkup(query.Message, tr(fromID, "lng_address_choose_asset"), true, markup)
+}
+
+// 回复添加地址
+func (handler *AddressBookHandler) replyAddAddress(bot *methods.BotExt, r *history.History, update *types.Update,
+	asset config.Asset) {
+
+	// 处理输入地址
+	back, err := r.Back()
+	if err == nil && back.Message != nil {
+		handler.handleAddAddress(bot, r, update, asset, back.Message.Text)
+		return
+	}
+
+	// 提示输入地址
+	r.Clear().Push(update)
+	query := update.CallbackQuery
+	fromID := query.From.ID
+	menus := [...]methods.InlineKeyboardButton{
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_back_superior"),
+			CallbackData: backSuperior(query.Data),
+		},
+	}
+	markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
+	reply := fmt.Sprintf(tr(fromID, "lng_address_add_say"), asset.Symbol, MaxAddressLabelLen)
+	bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
+	bot.AnswerCallbackQuery(query, tr(fromID, "lng_address_add_answer"), false, "", 0)
+}
+
+// 处理添加地址
+func (handler *AddressBookHandler) handleAddAddress(bot *methods.BotExt, r *history.History,
+	update *types.Update, asset config.Asset, text string) {
+
+	// 处理错误
+	query := update.CallbackQuery
+	fromID := query.From.ID
+	handlerError := func(reply string) {
+		r.Pop()
+		menus := [...]methods.InlineKeyboardButton{
+			methods.InlineKeyboardButton{
+				Text:         tr(fromID, "lng_back_superior"),
+				CallbackData: backSuperior(query.Data),
+			},
+		}
+		bot.AnswerCallbackQuery(query, "", false, "", 0)
+		markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
+		bot.SendMessage(fromID, reply, true, markup)
+	}
+
+	// 解析输入内容
+	fields := strings.Fields(text)
+	if len(fields) < 2 || len(fields) > 3 {
+		handlerError(tr(fromID, "lng_address_format_error"))
+		return
+	}
+	entry := models.AddressEntry{Symbol: asset.Symbol, Label: fields[0], Address: fields[1]}
+	if len(fields) == 3 {
+		entry.Memo = fields[2]
+	}
+
+	// 检查标签长度
+	if utf8.RuneCountInString(entry.Label) > MaxAddressLabelLen {
+		handlerError(fmt.Sprintf(tr(fromID, "lng_address_label_error"), MaxAddressLabelLen))
+		return
+	}
+
+	// 检查地址合法
+	if !scriptengine.Engine.ValidAddress(entry.Address, asset.Symbol) {
+		handlerError(tr(fromID, "lng_withdraw_account_error"))
+		return
+	}
+
+	// 保存地址
+	model := models.AddressBookModel{}
+	if _, err := model.AddAddress(fromID, &entry, MaxAddressBookSize); err != nil {
+		switch err {
+		case models.ErrAddressExists:
+			handlerError(tr(fromID, "lng_address_exists"))
+		case models.ErrAddressBookFull:
+			handlerError(fmt.Sprintf(tr(fromID, "lng_address_full"), MaxAddressBookSize))
+		default:
+			logger.Warnf("Failed to add address, user: %d, address: %s, %v", fromID, entry.Address, err)
+			handlerError(tr(fromID, "lng_address_add_error"))
+		}
+		return
+	}
+
+	// 回复地址列表
+	r.Clear()
+	handler.replyAddressBook(bot, query, false)
+}
+
+// 回复地址详情
+func (handler *AddressBookHandler) replyAddressDetail(bot *methods.BotExt, id uint64, query *types.CallbackQuery) {
+	fromID := query.From.ID
+	model := models.AddressBookModel{}
+	entry, err := model.GetAddress(fromID, id)
+	if err != nil {
+		bot.AnswerCallbackQuery(query, tr(fromID, "lng_address_not_found"), false, "", 0)
+		handler.replyAddressBook(bot, query, true)
+		return
+	}
+
+	menus := [...]methods.InlineKeyboardButton{
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_address_remove"),
+			CallbackData: query.Data + "remove/",
+		},
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_back_superior"),
+			CallbackData: backSuperior(query.Data),
+		},
+	}
+	markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
+	reply := fmt.Sprintf(tr(fromID, "lng_address_book_say"), makeAddressEntryMessage(fromID, entry))
+	bot.AnswerCallbackQuery(query, "", false, "", 0)
+	bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
+}
+
+// 处理删除地址
+func (handler *AddressBookHandler) handleRemoveAddress(bot *methods.BotExt, id uint64, query *types.CallbackQuery) {
+	fromID := query.From.ID
+	model := models.AddressBookModel{}
+	if err := model.RemoveAddress(fromID, id); err != nil {
+		bot.AnswerCallbackQuery(query, tr(fromID, "lng_address_not_found"), false, "", 0)
+	} else {
+		bot.AnswerCallbackQuery(query, tr(fromID, "lng_address_removed"), false, "", 0)
+	}
+	handler.replyAddressBook(bot, query, true)
+}
+
+// 生成地址信息
+func makeAddressEntryMessage(fromID int64, entry *models.AddressEntry) string {
+	memo := entry.Memo
+	if len(memo) == 0 {
+		memo = tr(fromID, "lng_address_no_memo")
+	}
+	symbol := entry.Symbol
+	if len(symbol) == 0 {
+		symbol = "-"
+	}
+	return fmt.Sprintf(tr(fromID, "lng_address_item"), entry.Label, symbol, entry.Address, memo)
+}
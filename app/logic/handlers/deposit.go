@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/zhangpanyi/basebot/history"
 	"github.com/zhangpanyi/basebot/telegram/methods"
@@ -10,29 +11,63 @@ import (
 	"luckybot/app/logic/scriptengine"
 )
 
+// 匹配资产
+var reMathDepositAsset *regexp.Regexp
+
+func init() {
+	var err error
+	reMathDepositAsset, err = regexp.Compile("^/deposit/(\\w+)/$")
+	if err != nil {
+		panic(err)
+	}
+}
+
 // 存款
 type DepositHandler struct {
 }
 
 // 消息处理
-func (*DepositHandler) Handle(bot *methods.BotExt, r *history.History, update *types.Update) {
-	serveCfg := config.GetServe()
-	fromID := update.CallbackQuery.From.ID
-	address, memo := scriptengine.Engine.DepositAddress(fromID)
+func (handler *DepositHandler) Handle(bot *methods.BotExt, r *history.History, update *types.Update) {
+	// 回复选择资产
+	query := update.CallbackQuery
+	if query.Data == "/deposit/" {
+		fromID := query.From.ID
+		markup := makeAssetMenus(fromID, query.Data, "/main/")
+		bot.AnswerCallbackQuery(query, "", false, "", 0)
+		bot.EditMessageReplyMarkup(query.Message, tr(fromID, "lng_deposit_choose_asset"), true, markup)
+		return
+	}
+
+	// 回复充值地址
+	result := reMathDepositAsset.FindStringSubmatch(query.Data)
+	if len(result) == 2 {
+		serveCfg := config.GetServe()
+		asset, ok := serveCfg.GetAsset(result[1])
+		if !ok {
+			return
+		}
+		handler.replyDepositAddress(bot, &asset, query)
+	}
+}
+
+// 回复充值地址
+func (*DepositHandler) replyDepositAddress(bot *methods.BotExt, asset *config.Asset, query *types.CallbackQuery) {
+	fromID := query.From.ID
+	address, memo := scriptengine.Engine.DepositAddress(fromID, asset.Symbol)
 	if len(memo) == 0 {
 		memo = tr(fromID, "lng_deposit_ignore")
 	}
-	reply := fmt.Sprintf(tr(fromID, "lng_deposit_say"), serveCfg.Name, serveCfg.Symbol,
-		address, memo, serveCfg.Precision)
+	reply := fmt.Sprintf(tr(fromID, "lng_deposit_say"), asset.Name, asset.Symbol,
+		address, memo, asset.Precision)
 	menus := [...]methods.InlineKeyboardButton{
 		methods.InlineKeyboardButton{
 			Text:         tr(fromID, "lng_back_superior"),
-			CallbackData: "/main/",
+			CallbackData: backSuperior(query.Data),
 		},
 	}
 	markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
-	bot.AnswerCallbackQuery(update.CallbackQuery, "", false, "", 0)
-	bot.EditMessageReplyMarkup(update.CallbackQuery.Message, reply, true, markup)
+	bot.AnswerCallbackQuery(query, "", false, "", 0)
+	bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
 }
 
 // 消息路由
@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"fmt"
-	"math/big"
 
-	"luckybot/app/fmath"
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"luckybot/app/config"
 	"luckybot/app/logic/handlers/utils"
 	"luckybot/app/storage/models"
 )
@@ -14,19 +14,41 @@ func tr(userID int64, key string) string {
 	return utils.Tr(userID, key)
 }
 
+// 生成资产菜单
+func makeAssetMenus(fromID int64, data, back string) *methods.InlineKeyboardMarkup {
+	serveCfg := config.GetServe()
+	menus := make([]methods.InlineKeyboardButton, 0, len(serveCfg.Assets))
+	for _, asset := range serveCfg.Assets {
+		menus = append(menus, methods.InlineKeyboardButton{
+			Text:         fmt.Sprintf("%s(%s)", asset.Name, asset.Symbol),
+			CallbackData: data + asset.Symbol + "/",
+		})
+	}
+
+	backMenus := [...]methods.InlineKeyboardButton{
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_back_superior"),
+			CallbackData: back,
+		},
+	}
+	markup := methods.MakeInlineKeyboardMarkupAuto(menus, 2)
+	return markup.Merge(methods.MakeInlineKeyboardMarkupAuto(backMenus[:], 1))
+}
+
 // 生成红包基本信息
 func makeBaseMessage(luckyMoney *models.LuckyMoney, received uint32) string {
-	tag := equalLuckyMoney
-	if luckyMoney.Lucky {
-		tag = randLuckyMoney
-	}
 	message := tr(luckyMoney.SenderID, "lng_luckymoney_info")
-	typ := luckyMoneysTypeToString(luckyMoney.SenderID, tag)
+	typ := luckyMoneysTypeToString(luckyMoney.SenderID, luckyMoneyType(luckyMoney))
 	amount := luckyMoney.Amount.String()
 	if !luckyMoney.Lucky {
-		amount = fmath.Mul(luckyMoney.Amount, big.NewFloat(float64(luckyMoney.Number))).String()
+		amount = luckyMoney.Amount.Mul(int64(luckyMoney.Number)).String()
 	}
-	return fmt.Sprintf(message, luckyMoney.ID, typ, luckyMoney.Number-received, luckyMoney.Number,
+	message = fmt.Sprintf(message, luckyMoney.ID, typ, luckyMoney.Number-received, luckyMoney.Number,
 		luckyMoney.SenderName, luckyMoney.SenderID,
 		amount, luckyMoney.Asset, typ, luckyMoney.Message)
+	if luckyMoney.Exclusive() {
+		recipients := formatRecipients(luckyMoney.Recipients, true)
+		message += fmt.Sprintf(tr(luckyMoney.SenderID, "lng_luckymoney_recipients"), recipients)
+	}
+	return message
 }
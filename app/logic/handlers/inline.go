@@ -7,7 +7,6 @@ import (
 	"github.com/zhangpanyi/basebot/telegram/methods"
 	"github.com/zhangpanyi/basebot/telegram/types"
 	"luckybot/app/config"
-	"luckybot/app/fmath"
 	"luckybot/app/location"
 	"luckybot/app/storage/models"
 )
@@ -93,10 +92,7 @@ func replyLuckyMoneyInfo(bot *methods.BotExt, query *types.InlineQuery) {
 
 // 生成红包信息
 func makeLuckyMoneyInfo(luckyMoney *models.LuckyMoney, received uint32, idx int) methods.InlineQueryResult {
-	tag := equalLuckyMoney
-	if luckyMoney.Lucky {
-		tag = randLuckyMoney
-	}
+	tag := luckyMoneyType(luckyMoney)
 	serveCfg := config.GetServe()
 	result := methods.InlineQueryResultArticle{}
 	result.ID = strconv.Itoa(idx)
@@ -129,9 +125,9 @@ func makeLuckyMoneyInfo(luckyMoney *models.LuckyMoney, received uint32, idx int)
 	reply := tr(luckyMoney.SenderID, "lng_luckymoney_item")
 	result.Description = fmt.Sprintf(reply,
 		luckyMoneysTypeToString(luckyMoney.SenderID, tag),
-		fmath.Sub(luckyMoney.Amount, luckyMoney.Received).String(),
+		luckyMoney.Amount.Sub(luckyMoney.Received).String(),
 		luckyMoney.Amount.String(),
-		serveCfg.Symbol,
+		luckyMoney.Asset,
 		luckyMoney.Number-received,
 		luckyMoney.Number,
 	)
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/zhangpanyi/basebot/history"
+	"github.com/zhangpanyi/basebot/logger"
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"github.com/zhangpanyi/basebot/telegram/types"
+	"luckybot/app/config"
+	"luckybot/app/logic/handlers/utils"
+)
+
+// 切换语言
+type LanguageHandler struct {
+}
+
+// 消息处理
+func (handler *LanguageHandler) Handle(bot *methods.BotExt, r *history.History, update *types.Update) {
+	if update.CallbackQuery == nil {
+		return
+	}
+
+	// 选择语言
+	query := update.CallbackQuery
+	code := strings.Trim(strings.TrimPrefix(query.Data, "/language/"), "/")
+	if len(code) == 0 {
+		handler.replyLanguages(bot, query)
+		return
+	}
+
+	// 设置语言
+	fromID := query.From.ID
+	if !config.GetLanguge().Has(code) {
+		bot.AnswerCallbackQuery(query, tr(fromID, "lng_language_invalid"), false, "", 0)
+		return
+	}
+	if err := utils.SetLanguage(fromID, code); err != nil {
+		logger.Warnf("Failed to set language, user: %d, %v", fromID, err)
+		bot.AnswerCallbackQuery(query, tr(fromID, "lng_language_invalid"), false, "", 0)
+		return
+	}
+
+	// 回复主菜单
+	bot.AnswerCallbackQuery(query, tr(fromID, "lng_language_changed"), false, "", 0)
+	mainmenu := MainMenuHandler{}
+	reply, menus := mainmenu.replyMessage(bot, fromID)
+	markup := methods.MakeInlineKeyboardMarkup(menus, mainMenuLayout...)
+	bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
+}
+
+// 消息路由
+func (*LanguageHandler) route(bot *methods.BotExt, query *types.CallbackQuery) Handler {
+	return nil
+}
+
+// 回复语言列表
+func (*LanguageHandler) replyLanguages(bot *methods.BotExt, query *types.CallbackQuery) {
+	fromID := query.From.ID
+	current := utils.GetLanguage(fromID)
+	languages := config.GetLanguge().Languages()
+	menus := make([]methods.InlineKeyboardButton, 0, len(languages))
+	for _, language := range languages {
+		text := language.Name
+		if language.Code == current {
+			text = "✅ " + text
+		}
+		menus = append(menus, methods.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: "/language/" + language.Code + "/",
+		})
+	}
+
+	backMenus := [...]methods.InlineKeyboardButton{
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_back_superior"),
+			CallbackData: "/main/",
+		},
+	}
+	markup := methods.MakeInlineKeyboardMarkupAuto(menus, 2)
+	markup = markup.Merge(methods.MakeInlineKeyboardMarkupAuto(backMenus[:], 1))
+
+	bot.AnswerCallbackQuery(query, "", false, "", 0)
+	bot.EditMessageReplyMarkup(query.Message, tr(fromID, "lng_language_say"), true, markup)
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"fmt"
-	"math/big"
 	"strings"
 
 	"github.com/zhangpanyi/basebot/history"
@@ -10,6 +9,7 @@ import (
 	"github.com/zhangpanyi/basebot/telegram/methods"
 	"github.com/zhangpanyi/basebot/telegram/types"
 	"luckybot/app/config"
+	"luckybot/app/fmath"
 	"luckybot/app/storage"
 	"luckybot/app/storage/models"
 )
@@ -20,6 +20,9 @@ type Handler interface {
 	Handle(*methods.BotExt, *history.History, *types.Update)
 }
 
+// 主菜单布局
+var mainMenuLayout = []int{2, 2, 2, 2, 1}
+
 // 主菜单
 type MainMenuHandler struct {
 }
@@ -55,8 +58,8 @@ func (handler *MainMenuHandler) Handle(bot *methods.BotExt, r *history.History,
 
 		// 发送菜单列表
 		r.Clear()
-		reply, menus := handler.replyMessage(update.Message.From.ID)
-		markup := methods.MakeInlineKeyboardMarkup(menus, 2, 2, 2, 1)
+		reply, menus := handler.replyMessage(bot, update.Message.From.ID)
+		markup := methods.MakeInlineKeyboardMarkup(menus, mainMenuLayout...)
 		bot.SendMessage(update.Message.Chat.ID, reply, true, markup)
 		return
 	}
@@ -69,8 +72,8 @@ func (handler *MainMenuHandler) Handle(bot *methods.BotExt, r *history.History,
 	if update.CallbackQuery.Data == "/main/" {
 		r.Clear()
 		bot.AnswerCallbackQuery(update.CallbackQuery, "", false, "", 0)
-		reply, menus := handler.replyMessage(update.CallbackQuery.From.ID)
-		markup := methods.MakeInlineKeyboardMarkup(menus, 2, 2, 2, 1)
+		reply, menus := handler.replyMessage(bot, update.CallbackQuery.From.ID)
+		markup := methods.MakeInlineKeyboardMarkup(menus, mainMenuLayout...)
 		bot.EditMessageReplyMarkup(update.CallbackQuery.Message, reply, true, markup)
 		return
 	}
@@ -119,33 +122,50 @@ func (handler *MainMenuHandler) route(bot *methods.BotExt, query *types.Callback
 	if strings.HasPrefix(query.Data, "/withdraw/") {
 		return new(WithdrawHandler)
 	}
+
+	// 地址簿操作
+	if strings.HasPrefix(query.Data, "/address/") {
+		return new(AddressBookHandler)
+	}
+
+	// 切换语言
+	if strings.HasPrefix(query.Data, "/language/") {
+		return new(LanguageHandler)
+	}
 	return nil
 }
 
 // 获取用户资产数量
-func getUserBalance(userID int64, asset string) (*big.Float, *big.Float) {
+func getUserBalance(userID int64, asset string) (fmath.Decimal, fmath.Decimal) {
 	model := models.AccountModel{}
 	account, err := model.GetAccount(userID, asset)
 	if err != nil {
 		if err != storage.ErrNoBucket && err != models.ErrNoSuchTypeAccount {
 			logger.Warnf("Failed to get user asset, %v, %v, %v", userID, asset, err)
 		}
-		return big.NewFloat(0), big.NewFloat(0)
+		return fmath.Zero(0), fmath.Zero(0)
 	}
 	return account.Amount, account.Locked
 }
 
 // 获取回复消息
-func (handler *MainMenuHandler) replyMessage(userID int64) (string, []methods.InlineKeyboardButton) {
+func (handler *MainMenuHandler) replyMessage(bot *methods.BotExt, userID int64) (string, []methods.InlineKeyboardButton) {
 	// 获取资产信息
 	serveCfg := config.GetServe()
-	amount, locked := getUserBalance(userID, serveCfg.Symbol)
-	if serveCfg.Test && amount.Cmp(big.NewFloat(0)) == 0 {
-		model := models.AccountModel{}
-		account, err := model.Deposit(userID, serveCfg.Symbol, big.NewFloat(1000))
-		if err == nil {
-			amount, locked = account.Amount, account.Locked
+	balances := make([]string, 0, len(serveCfg.Assets))
+	for _, asset := range serveCfg.Assets {
+		amount, locked := getUserBalance(userID, asset.Symbol)
+		if serveCfg.Test && amount.IsZero() {
+			value := fmath.FromInt(1000, asset.Precision)
+			model := models.LedgerModel{}
+			accounts, err := model.Post(models.NewJournal(asset.Symbol).Deposit(userID, value).
+				Record(userID, &models.Version{Balance: &value, Reason: models.ReasonSystem}))
+			if err == nil {
+				amount, locked = accounts[userID].Amount, accounts[userID].Locked
+			}
 		}
+		balances = append(balances, fmt.Sprintf(tr(userID, "lng_welcome_asset"), asset.Name,
+			asset.Symbol, amount.String(), asset.Symbol, locked.String(), asset.Symbol))
 	}
 
 	// 生成菜单列表
@@ -154,11 +174,12 @@ func (handler *MainMenuHandler) replyMessage(userID int64) (string, []methods.In
 		methods.InlineKeyboardButton{Text: tr(userID, "lng_history"), CallbackData: "/history/"},
 		methods.InlineKeyboardButton{Text: tr(userID, "lng_deposit"), CallbackData: "/deposit/"},
 		methods.InlineKeyboardButton{Text: tr(userID, "lng_withdraw"), CallbackData: "/withdraw/"},
+		methods.InlineKeyboardButton{Text: tr(userID, "lng_address_book"), CallbackData: "/address/"},
 		methods.InlineKeyboardButton{Text: tr(userID, "lng_rate"), CallbackData: "/rate/"},
 		methods.InlineKeyboardButton{Text: tr(userID, "lng_share"), CallbackData: "/share/"},
 		methods.InlineKeyboardButton{Text: tr(userID, "lng_help"), CallbackData: "/usage/"},
+		methods.InlineKeyboardButton{Text: tr(userID, "lng_language"), CallbackData: "/language/"},
 	}
-	reply := fmt.Sprintf(tr(userID, "lng_welcome"), serveCfg.Name, serveCfg.Symbol,
-		amount.String(), serveCfg.Symbol, locked.String(), serveCfg.Symbol)
+	reply := fmt.Sprintf(tr(userID, "lng_welcome"), bot.FirstName, strings.Join(balances, "\n\n"))
 	return reply, menus[:]
 }
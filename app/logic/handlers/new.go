@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/zhangpanyi/basebot/history"
 	"github.com/zhangpanyi/basebot/logger"
@@ -19,6 +20,9 @@ import (
 	"luckybot/app/storage/models"
 )
 
+// 匹配资产
+var reMathAsset *regexp.Regexp
+
 // 匹配类型
 var reMathType *regexp.Regexp
 
@@ -28,19 +32,40 @@ var reMathAmount *regexp.Regexp
 // 匹配数量
 var reMathNumber *regexp.Regexp
 
+// 匹配领取人
+var reMathRecipients *regexp.Regexp
+
+// 匹配单个领取人
+var reMathRecipient *regexp.Regexp
+
 func init() {
 	var err error
-	reMathType, err = regexp.Compile("^/new/(rand|equal)/$")
+	reMathAsset, err = regexp.Compile("^/new/(\\w+)/$")
+	if err != nil {
+		panic(err)
+	}
+
+	reMathType, err = regexp.Compile("^/new/(\\w+)/(rand|equal|exclusive)/$")
+	if err != nil {
+		panic(err)
+	}
+
+	reMathAmount, err = regexp.Compile("^/new/(\\w+)/(rand|equal|exclusive)/([0-9]+\\.?[0-9]*)/$")
 	if err != nil {
 		panic(err)
 	}
 
-	reMathAmount, err = regexp.Compile("^/new/(rand|equal)/([0-9]+\\.?[0-9]*)/$")
+	reMathNumber, err = regexp.Compile("^/new/(\\w+)/(rand|equal)/([0-9]+\\.?[0-9]*)/(\\d+)/$")
 	if err != nil {
 		panic(err)
 	}
 
-	reMathNumber, err = regexp.Compile("^/new/(rand|equal)/([0-9]+\\.?[0-9]*)/(\\d+)/$")
+	reMathRecipients, err = regexp.Compile("^/new/(\\w+)/exclusive/([0-9]+\\.?[0-9]*)/([\\w,]+)/$")
+	if err != nil {
+		panic(err)
+	}
+
+	reMathRecipient, err = regexp.Compile("^@?([A-Za-z]\\w{0,31}|\\d{1,20})$")
 	if err != nil {
 		panic(err)
 	}
@@ -51,14 +76,22 @@ var (
 	randLuckyMoney = "rand"
 	// 普通红包
 	equalLuckyMoney = "equal"
+	// 专属红包
+	exclusiveLuckyMoney = "exclusive"
 )
 
+// 专属红包领取人上限
+const maxRecipients = 20
+
 // 红包信息
 type luckyMoneys struct {
-	typ     string     // 红包类型
-	amount  *big.Float // 红包金额
-	number  int        // 红包个数
-	message string     // 红包留言
+	asset   config.Asset  // 资产类型
+	typ     string        // 红包类型
+	amount  fmath.Decimal // 红包金额
+	number  int           // 红包个数
+	message string        // 红包留言
+
+	recipients []models.LuckyMoneyRecipient // 专属领取人
 }
 
 // 红包类型转字符串
@@ -66,59 +99,195 @@ func luckyMoneysTypeToString(fromID int64, typ string) string {
 	if typ == randLuckyMoney {
 		return tr(fromID, "lng_new_rand")
 	}
+	if typ == exclusiveLuckyMoney {
+		return tr(fromID, "lng_new_exclusive")
+	}
 	return tr(fromID, "lng_new_equal")
 }
 
+// 获取红包类型
+func luckyMoneyType(luckyMoney *models.LuckyMoney) string {
+	if luckyMoney.Lucky {
+		return randLuckyMoney
+	}
+	if luckyMoney.Exclusive() {
+		return exclusiveLuckyMoney
+	}
+	return equalLuckyMoney
+}
+
+// 解析专属领取人
+// 用户名或用户ID以空格或逗号分隔，用户名统一转为小写
+func parseRecipients(text string) ([]models.LuckyMoneyRecipient, bool) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == '，' || unicode.IsSpace(r)
+	})
+	if len(fields) == 0 || len(fields) > maxRecipients {
+		return nil, false
+	}
+
+	exists := make(map[string]bool)
+	recipients := make([]models.LuckyMoneyRecipient, 0, len(fields))
+	for _, field := range fields {
+		result := reMathRecipient.FindStringSubmatch(field)
+		if len(result) != 2 {
+			return nil, false
+		}
+
+		name := strings.ToLower(result[1])
+		if exists[name] {
+			continue
+		}
+		exists[name] = true
+
+		recipient := models.LuckyMoneyRecipient{UserName: name}
+		if userID, err := strconv.ParseInt(name, 10, 64); err == nil {
+			if userID <= 0 {
+				return nil, false
+			}
+			recipient = models.LuckyMoneyRecipient{UserID: userID}
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, true
+}
+
+// 格式化专属领取人
+func formatRecipients(recipients []models.LuckyMoneyRecipient, markdown bool) string {
+	items := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		if recipient.UserID != 0 {
+			userID := strconv.FormatInt(recipient.UserID, 10)
+			if markdown {
+				items = append(items, fmt.Sprintf("[%s](tg://user?id=%s)", userID, userID))
+			} else {
+				items = append(items, userID)
+			}
+			continue
+		}
+
+		name := "@" + recipient.UserName
+		if markdown {
+			name = strings.Replace(name, "_", "\\_", -1)
+		}
+		items = append(items, name)
+	}
+	return strings.Join(items, " ")
+}
+
+// 编码专属领取人
+func encodeRecipients(recipients []models.LuckyMoneyRecipient) string {
+	items := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		if recipient.UserID != 0 {
+			items = append(items, strconv.FormatInt(recipient.UserID, 10))
+		} else {
+			items = append(items, recipient.UserName)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
 // 创建红包
 type NewHandler struct {
 }
 
 // 消息处理
 func (handler *NewHandler) Handle(bot *methods.BotExt, r *history.History, update *types.Update) {
-	// 回复选择红包类型
+	// 回复选择红包资产
 	data := update.CallbackQuery.Data
 	if data == "/new/" {
 		r.Clear()
-		handler.replyChooseType(bot, update.CallbackQuery)
+		handler.replyChooseAsset(bot, update.CallbackQuery)
 		return
 	}
 
-	// 回复输入红包金额
+	// 回复选择红包类型
+	var ok bool
 	info := luckyMoneys{}
-	result := reMathType.FindStringSubmatch(data)
+	serveCfg := config.GetServe()
+	result := reMathAsset.FindStringSubmatch(data)
 	if len(result) == 2 {
-		info.typ = result[1]
+		info.asset, ok = serveCfg.GetAsset(result[1])
+		if !ok {
+			return
+		}
+		r.Clear()
+		handler.replyChooseType(bot, &info, update.CallbackQuery)
+		return
+	}
+
+	// 回复输入红包金额
+	result = reMathType.FindStringSubmatch(data)
+	if len(result) == 3 {
+		info.asset, ok = serveCfg.GetAsset(result[1])
+		if !ok {
+			return
+		}
+		info.typ = result[2]
 		handler.replyEnterAmount(bot, r, &info, update)
 		return
 	}
 
 	// 回复输入红包数量
-	var ok bool
 	result = reMathAmount.FindStringSubmatch(data)
-	if len(result) == 3 {
-		info.typ = result[1]
-		info.amount, ok = big.NewFloat(0).SetString(result[2])
+	if len(result) == 4 {
+		info.asset, ok = serveCfg.GetAsset(result[1])
 		if !ok {
 			return
 		}
+		info.typ = result[2]
+		info.amount, ok = fmath.Parse(result[3], info.asset.Precision)
+		if !ok {
+			return
+		}
+		if info.typ == exclusiveLuckyMoney {
+			handler.replyEnterRecipients(bot, r, &info, update, true)
+			return
+		}
 		handler.replyEnterNumber(bot, r, &info, update, true)
 		return
 	}
 
 	// 回复输入红包留言
 	result = reMathNumber.FindStringSubmatch(data)
-	if len(result) == 4 {
-		info.typ = result[1]
-		info.amount, ok = big.NewFloat(0).SetString(result[2])
+	if len(result) == 5 {
+		info.asset, ok = serveCfg.GetAsset(result[1])
 		if !ok {
 			return
 		}
-		number, _ := strconv.Atoi(result[3])
+		info.typ = result[2]
+		info.amount, ok = fmath.Parse(result[3], info.asset.Precision)
+		if !ok {
+			return
+		}
+		number, _ := strconv.Atoi(result[4])
 		info.number = number
 		handler.replyEnterMessage(bot, r, &info, update)
 		return
 	}
 
+	// 回复输入专属红包留言
+	result = reMathRecipients.FindStringSubmatch(data)
+	if len(result) == 4 {
+		info.asset, ok = serveCfg.GetAsset(result[1])
+		if !ok {
+			return
+		}
+		info.typ = exclusiveLuckyMoney
+		info.amount, ok = fmath.Parse(result[2], info.asset.Precision)
+		if !ok {
+			return
+		}
+		info.recipients, ok = parseRecipients(result[3])
+		if !ok {
+			return
+		}
+		info.number = len(info.recipients)
+		handler.replyEnterMessage(bot, r, &info, update)
+		return
+	}
+
 	// 路由到其它处理模块
 	newHandler := handler.route(bot, update.CallbackQuery)
 	if newHandler == nil {
@@ -156,8 +325,20 @@ func makeBaseMenus(fromID int64, data string) *methods.InlineKeyboardMarkup {
 	return methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
 }
 
+// 回复选择资产类型
+func (handler *NewHandler) replyChooseAsset(bot *methods.BotExt, query *types.CallbackQuery) {
+	// 生成菜单列表
+	fromID := query.From.ID
+	markup := makeAssetMenus(fromID, query.Data, "/main/")
+
+	// 回复请求结果
+	reply := tr(fromID, "lng_new_choose_asset")
+	bot.AnswerCallbackQuery(query, "", false, "", 0)
+	bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
+}
+
 // 回复输入选择类型
-func (handler *NewHandler) replyChooseType(bot *methods.BotExt, query *types.CallbackQuery) {
+func (handler *NewHandler) replyChooseType(bot *methods.BotExt, info *luckyMoneys, query *types.CallbackQuery) {
 
 	// 生成菜单列表
 	data := query.Data
@@ -171,15 +352,19 @@ func (handler *NewHandler) replyChooseType(bot *methods.BotExt, query *types.Cal
 			Text:         tr(fromID, "lng_new_equal"),
 			CallbackData: data + equalLuckyMoney + "/",
 		},
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_new_exclusive"),
+			CallbackData: data + exclusiveLuckyMoney + "/",
+		},
 		methods.InlineKeyboardButton{
 			Text:         tr(fromID, "lng_back_superior"),
-			CallbackData: "/main/",
+			CallbackData: backSuperior(data),
 		},
 	}
 
 	// 回复请求结果
-	reply := tr(fromID, "lng_new_choose_type")
-	markup := methods.MakeInlineKeyboardMarkup(menus[:], 2, 1)
+	reply := fmt.Sprintf(tr(fromID, "lng_new_choose_type"), info.asset.Symbol)
+	markup := methods.MakeInlineKeyboardMarkup(menus[:], 2, 1, 1)
 	bot.AnswerCallbackQuery(query, "", false, "", 0)
 	bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
 }
@@ -202,25 +387,17 @@ func (handler *NewHandler) handleEnterAmount(bot *methods.BotExt, r *history.His
 	}
 
 	// 检查输入金额
-	serveCfg := config.GetServe()
-	amount, ok := big.NewFloat(0).SetString(enterAmount)
-	if !ok || amount.Cmp(big.NewFloat(0)) <= 0 {
-		handlerError(fmt.Sprintf(tr(fromID, "lng_new_set_amount_error"), serveCfg.Precision))
-		return
-	}
-
-	// 检查小数点位数
-	s := strings.Split(enterAmount, ".")
-	if len(s) == 2 && len(s[1]) > serveCfg.Precision {
-		handlerError(fmt.Sprintf(tr(fromID, "lng_new_set_amount_error"), serveCfg.Precision))
+	amount, ok := fmath.Parse(enterAmount, info.asset.Precision)
+	if !ok || amount.Sign() <= 0 {
+		handlerError(fmt.Sprintf(tr(fromID, "lng_new_set_amount_error"), info.asset.Precision))
 		return
 	}
 
 	// 检查帐户余额
-	balance, _ := getUserBalance(fromID, serveCfg.Symbol)
+	balance, _ := getUserBalance(fromID, info.asset.Symbol)
 	if amount.Cmp(balance) == 1 {
 		reply := tr(fromID, "lng_new_set_amount_no_asset")
-		handlerError(fmt.Sprintf(reply, serveCfg.Symbol, balance))
+		handlerError(fmt.Sprintf(reply, info.asset.Symbol, balance))
 		return
 	}
 
@@ -228,6 +405,10 @@ func (handler *NewHandler) handleEnterAmount(bot *methods.BotExt, r *history.His
 	r.Clear()
 	info.amount = amount
 	update.CallbackQuery.Data = data + enterAmount + "/"
+	if info.typ == exclusiveLuckyMoney {
+		handler.replyEnterRecipients(bot, r, info, update, false)
+		return
+	}
 	handler.replyEnterNumber(bot, r, info, update, false)
 }
 
@@ -250,28 +431,23 @@ func (handler *NewHandler) replyEnterAmount(bot *methods.BotExt, r *history.Hist
 	// 回复请求结果
 	r.Clear().Push(update)
 	amountDesc := tr(fromID, "lng_new_total_amount")
-	if info.typ == equalLuckyMoney {
+	if info.typ != randLuckyMoney {
 		amountDesc = tr(fromID, "lng_new_unit_amount")
 	}
 
-	serveCfg := config.GetServe()
-	answer := fmt.Sprintf(tr(fromID, "lng_new_set_amount_answer"), amountDesc, serveCfg.Precision)
+	answer := fmt.Sprintf(tr(fromID, "lng_new_set_amount_answer"), amountDesc, info.asset.Precision)
 	bot.AnswerCallbackQuery(query, answer, false, "", 0)
 
 	reply := tr(fromID, "lng_new_set_amount")
-	amount, _ := getUserBalance(fromID, serveCfg.Symbol)
-	reply = fmt.Sprintf(reply, amountDesc, serveCfg.Precision, luckyMoneysTypeToString(fromID, info.typ),
-		serveCfg.Symbol, amount.String())
+	amount, _ := getUserBalance(fromID, info.asset.Symbol)
+	reply = fmt.Sprintf(reply, amountDesc, info.asset.Precision, luckyMoneysTypeToString(fromID, info.typ),
+		info.asset.Symbol, amount.String())
 	bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
 }
 
 // 最低单个金额
-func minSingleAmount() *big.Float {
-	base := big.NewInt(10)
-	serveCfg := config.GetServe()
-	base.Exp(base, big.NewInt(int64(serveCfg.Precision)), nil)
-	wei, _ := big.NewFloat(0).SetString(base.String())
-	return wei.Quo(big.NewFloat(1), wei)
+func minSingleAmount(precision int) fmath.Decimal {
+	return fmath.NewDecimal(big.NewInt(1), precision)
 }
 
 // 处理输入红包个数
@@ -291,30 +467,25 @@ func (handler *NewHandler) handleEnterNumber(bot *methods.BotExt, r *history.His
 	}
 
 	// 检查红包数量
-	serveCfg := config.GetServe()
+	precision := info.asset.Precision
 	number, err := strconv.Atoi(enterNumber)
 	if err != nil || number <= 0 {
-		handlerError(fmt.Sprintf(tr(fromID, "lng_new_set_number_error"), minSingleAmount().String()))
+		handlerError(fmt.Sprintf(tr(fromID, "lng_new_set_number_error"), minSingleAmount(precision).String()))
 		return
 	}
 
 	// 检查账户余额
-	balance, _ := getUserBalance(fromID, serveCfg.Symbol)
+	balance, _ := getUserBalance(fromID, info.asset.Symbol)
 	if info.typ == equalLuckyMoney {
-		if fmath.Mul(info.amount, big.NewFloat(float64(number))).Cmp(balance) == 1 {
+		if info.amount.Mul(int64(number)).Cmp(balance) == 1 {
 			reply := tr(fromID, "lng_new_set_number_not_enough")
-			handlerError(fmt.Sprintf(reply, serveCfg.Symbol, balance.String()))
+			handlerError(fmt.Sprintf(reply, info.asset.Symbol, balance.String()))
 			return
 		}
 	} else if info.typ == randLuckyMoney {
-		base := big.NewInt(10)
-		base.Exp(base, big.NewInt(int64(serveCfg.Precision)), nil)
-		wei, _ := big.NewFloat(0).SetString(base.String())
-		product := fmath.Mul(wei, info.amount)
-		unit, _ := product.Int(big.NewInt(0))
-		if unit.Cmp(big.NewInt(int64(number))) == -1 {
+		if info.amount.Units().Cmp(big.NewInt(int64(number))) == -1 {
 			reply := tr(fromID, "lng_new_set_number_not_enough")
-			handlerError(fmt.Sprintf(reply, serveCfg.Symbol, balance.String()))
+			handlerError(fmt.Sprintf(reply, info.asset.Symbol, balance.String()))
 			return
 		}
 	}
@@ -348,10 +519,9 @@ func (handler *NewHandler) replyEnterNumber(bot *methods.BotExt, r *history.Hist
 		amountDesc = tr(fromID, "lng_new_unit_amount")
 	}
 
-	serveCfg := config.GetServe()
 	reply := tr(fromID, "lng_new_set_number")
-	reply = fmt.Sprintf(reply, minSingleAmount().String(), luckyMoneysTypeToString(fromID, info.typ),
-		amountDesc, info.amount.String(), serveCfg.Symbol)
+	reply = fmt.Sprintf(reply, minSingleAmount(info.asset.Precision).String(),
+		luckyMoneysTypeToString(fromID, info.typ), amountDesc, info.amount.String(), info.asset.Symbol)
 
 	if !edit {
 		bot.SendMessage(fromID, reply, true, markup)
@@ -361,6 +531,74 @@ func (handler *NewHandler) replyEnterNumber(bot *methods.BotExt, r *history.Hist
 	bot.AnswerCallbackQuery(query, tr(fromID, "lng_new_set_number_answer"), false, "", 0)
 }
 
+// 处理输入专属领取人
+func (handler *NewHandler) handleEnterRecipients(bot *methods.BotExt, r *history.History,
+	info *luckyMoneys, update *types.Update, enterRecipients string) {
+
+	// 生成菜单列表
+	query := update.CallbackQuery
+	fromID := query.From.ID
+
+	// 处理错误
+	handlerError := func(reply string) {
+		r.Pop()
+		markup := makeBaseMenus(fromID, query.Data)
+		bot.AnswerCallbackQuery(query, "", false, "", 0)
+		bot.SendMessage(fromID, reply, true, markup)
+	}
+
+	// 检查领取人
+	recipients, ok := parseRecipients(enterRecipients)
+	if !ok {
+		handlerError(fmt.Sprintf(tr(fromID, "lng_new_set_recipients_error"), maxRecipients))
+		return
+	}
+
+	// 检查账户余额
+	balance, _ := getUserBalance(fromID, info.asset.Symbol)
+	if info.amount.Mul(int64(len(recipients))).Cmp(balance) == 1 {
+		reply := tr(fromID, "lng_new_set_number_not_enough")
+		handlerError(fmt.Sprintf(reply, info.asset.Symbol, balance.String()))
+		return
+	}
+
+	// 更新下个操作状态
+	r.Clear()
+	info.recipients = recipients
+	info.number = len(recipients)
+	update.CallbackQuery.Data += encodeRecipients(recipients) + "/"
+	handler.replyEnterMessage(bot, r, info, update)
+}
+
+// 回复输入专属领取人
+func (handler *NewHandler) replyEnterRecipients(bot *methods.BotExt, r *history.History, info *luckyMoneys,
+	update *types.Update, edit bool) {
+
+	// 处理输入领取人
+	back, err := r.Back()
+	if err == nil && back.Message != nil {
+		handler.handleEnterRecipients(bot, r, info, update, back.Message.Text)
+		return
+	}
+
+	// 提示输入领取人
+	r.Clear().Push(update)
+	query := update.CallbackQuery
+	fromID := query.From.ID
+	markup := makeBaseMenus(fromID, query.Data)
+
+	reply := tr(fromID, "lng_new_set_recipients")
+	reply = fmt.Sprintf(reply, maxRecipients, luckyMoneysTypeToString(fromID, info.typ),
+		tr(fromID, "lng_new_unit_amount"), info.amount.String(), info.asset.Symbol)
+
+	if !edit {
+		bot.SendMessage(fromID, reply, true, markup)
+	} else {
+		bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
+	}
+	bot.AnswerCallbackQuery(query, tr(fromID, "lng_new_set_recipients_answer"), false, "", 0)
+}
+
 // 处理输入红包留言
 func (handler *NewHandler) handleEnterMessage(bot *methods.BotExt, r *history.History,
 	info *luckyMoneys, update *types.Update, message string) {
@@ -440,13 +678,15 @@ func (handler *NewHandler) replyEnterMessage(bot *methods.BotExt, r *history.His
 	// 提示输入红包留言
 	r.Clear().Push(update)
 	amount := tr(fromID, "lng_new_total_amount")
-	if info.typ == equalLuckyMoney {
+	if info.typ != randLuckyMoney {
 		amount = tr(fromID, "lng_new_unit_amount")
 	}
-	serveCfg := config.GetServe()
 	reply := tr(fromID, "lng_new_set_message")
-	reply = fmt.Sprintf(reply, luckyMoneysTypeToString(fromID, info.typ), serveCfg.Symbol,
-		amount, info.amount.String(), serveCfg.Symbol, info.number)
+	reply = fmt.Sprintf(reply, luckyMoneysTypeToString(fromID, info.typ), info.asset.Symbol,
+		amount, info.amount.String(), info.asset.Symbol, info.number)
+	if info.typ == exclusiveLuckyMoney {
+		reply += fmt.Sprintf(tr(fromID, "lng_new_recipients"), formatRecipients(info.recipients, true))
+	}
 	bot.SendMessage(fromID, reply, true, markup)
 	bot.AnswerCallbackQuery(query, tr(fromID, "lng_new_set_message_answer"), false, "", 0)
 }
@@ -456,73 +696,50 @@ func (handler *NewHandler) handleGenerateLuckyMoney(userID int64, firstName stri
 	info *luckyMoneys) (*models.LuckyMoney, error) {
 
 	// 生成红包
-	var luckyMoneyArr []*big.Float
-	amount := big.NewFloat(0).Set(info.amount)
-	if info.typ == equalLuckyMoney {
-		amount.Mul(amount, big.NewFloat(float64(info.number)))
+	var luckyMoneyArr []fmath.Decimal
+	amount := info.amount
+	if info.typ != randLuckyMoney {
+		amount = amount.Mul(int64(info.number))
 	}
 	if info.typ == randLuckyMoney {
 		var err error
-		serveCfg := config.GetServe()
-		luckyMoneyArr, err = algo.Generate(amount, serveCfg.Precision, info.number)
+		luckyMoneyArr, err = algo.Generate(amount, info.number)
 		if err != nil {
 			logger.Errorf("Failed to generate lucky money, user_id: %v, %v", userID, err)
 			return nil, err
 		}
 	} else {
-		luckyMoneyArr = make([]*big.Float, 0, info.number)
+		luckyMoneyArr = make([]fmath.Decimal, 0, info.number)
 		for i := 0; i < info.number; i++ {
 			luckyMoneyArr = append(luckyMoneyArr, info.amount)
 		}
 	}
 
-	// 锁定资金
-	serveCfg := config.GetServe()
-	model := models.AccountModel{}
-	account, err := model.LockAccount(userID, serveCfg.Symbol, amount)
-	if err != nil {
-		return nil, err
-	}
-	logger.Errorf("Lock account, user_id: %v, asset: %v, amount: %v",
-		userID, serveCfg.Symbol, amount.String())
-
 	// 保存红包信息
+	symbol := info.asset.Symbol
 	luckyMoney := models.LuckyMoney{
 		SenderID:   userID,
 		SenderName: firstName,
-		Asset:      serveCfg.Symbol,
+		Asset:      symbol,
 		Amount:     info.amount,
 		Number:     uint32(info.number),
 		Message:    info.message,
 		Lucky:      info.typ == randLuckyMoney,
 		Timestamp:  time.Now().UTC().Unix(),
+		Recipients: info.recipients,
 	}
-	if info.typ == equalLuckyMoney {
-		luckyMoney.Value = big.NewFloat(0).Set(info.amount)
+	if info.typ != randLuckyMoney {
+		value := info.amount
+		luckyMoney.Value = &value
 	}
 	luckyMoneyModel := models.LuckyMoneyModel{}
 	data, err := luckyMoneyModel.NewLuckyMoney(&luckyMoney, luckyMoneyArr)
 	if err != nil {
-		// 解锁资金
-		if _, err := model.UnlockAccount(userID, serveCfg.Symbol, amount); err != nil {
-			logger.Errorf("Failed to unlock asset, user_id: %v, asset: %v, amount: %v",
-				userID, serveCfg.Symbol, amount.String())
-		}
 		logger.Errorf("Failed to new lucky money, user_id: %v, %v", userID, err)
 		return nil, err
 	}
 	logger.Errorf("Generate lucky money, id: %v, user_id: %v, asset: %v, amount: %v",
-		data.ID, userID, serveCfg.Symbol, amount.String())
-
-	// 插入账户记录
-	versionModel := models.AccountVersionModel{}
-	versionModel.InsertVersion(userID, &models.Version{
-		Symbol:          serveCfg.Symbol,
-		Locked:          amount,
-		Amount:          account.Amount,
-		Reason:          models.ReasonGive,
-		RefLuckyMoneyID: &luckyMoney.ID,
-	})
+		data.ID, userID, symbol, amount.String())
 
 	// 添加到检查队列
 	monitor.AddToQueue(luckyMoney.ID, luckyMoney.Timestamp)
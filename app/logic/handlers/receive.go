@@ -14,7 +14,8 @@ import (
 )
 
 // 回复红包信息
-func ReplyLuckyMoneyInfo(bot *methods.BotExt, fromID int64, inlineMessageID string,
+// 红包消息由群内所有人共享，统一使用发送者的语言
+func ReplyLuckyMoneyInfo(bot *methods.BotExt, inlineMessageID string,
 	luckyMoney *models.LuckyMoney, received uint32, expired bool) {
 
 	// 获取领取记录
@@ -26,9 +27,10 @@ func ReplyLuckyMoneyInfo(bot *methods.BotExt, fromID int64, inlineMessageID stri
 		logger.Errorf("Failed to get lucky money history, %v", err)
 	}
 	serveCfg := config.GetServe()
+	senderID := luckyMoney.SenderID
 	for i := 0; i < len(history); i++ {
 		user := history[i].User
-		message := tr(fromID, "lng_chat_receive_history")
+		message := tr(senderID, "lng_chat_receive_history")
 		message = fmt.Sprintf(message, user.FirstName, user.UserID, history[i].Value.String(), luckyMoney.Asset)
 
 		size += len(message)
@@ -43,17 +45,17 @@ func ReplyLuckyMoneyInfo(bot *methods.BotExt, fromID int64, inlineMessageID stri
 	menus := make([]methods.InlineKeyboardButton, 0)
 	if received == luckyMoney.Number {
 		menus = append(menus, methods.InlineKeyboardButton{
-			Text:         tr(fromID, "lng_chat_finished"),
+			Text:         tr(senderID, "lng_chat_finished"),
 			CallbackData: "removed",
 		})
 	} else if expired {
 		menus = append(menus, methods.InlineKeyboardButton{
-			Text:         tr(fromID, "lng_chat_expired"),
+			Text:         tr(senderID, "lng_chat_expired"),
 			CallbackData: "expired",
 		})
 	} else {
 		menus = append(menus, methods.InlineKeyboardButton{
-			Text:         tr(fromID, "lng_chat_receive"),
+			Text:         tr(senderID, "lng_chat_receive"),
 			CallbackData: luckyMoney.SN,
 		})
 	}
@@ -64,7 +66,7 @@ func ReplyLuckyMoneyInfo(bot *methods.BotExt, fromID int64, inlineMessageID stri
 	if received == luckyMoney.Number {
 		best, worst, err := model.GetBestAndWorst(luckyMoney.ID)
 		if err == nil && luckyMoney.Number > 1 && luckyMoney.Lucky {
-			settle = tr(fromID, "lng_chat_receive_settle")
+			settle = tr(senderID, "lng_chat_receive_settle")
 			settle = fmt.Sprintf(settle,
 				best.User.FirstName, best.User.UserID, best.Value.String(), luckyMoney.Asset,
 				worst.User.FirstName, worst.User.UserID, worst.Value.String(), luckyMoney.Asset)
@@ -74,7 +76,7 @@ func ReplyLuckyMoneyInfo(bot *methods.BotExt, fromID int64, inlineMessageID stri
 	// 更新红包信息
 	message := makeBaseMessage(luckyMoney, received)
 	if len(users) > 0 {
-		message = fmt.Sprintf(tr(fromID, "lng_chat_receive_format"), message, strings.Join(users, ","), settle)
+		message = fmt.Sprintf(tr(senderID, "lng_chat_receive_format"), message, strings.Join(users, ","), settle)
 	}
 	bot.EditReplyMarkupByInlineMessageID(inlineMessageID, message, true, replyMarkup)
 }
@@ -120,6 +122,12 @@ func (handler *ReceiveHandler) answerReceiveError(bot *methods.BotExt, query *ty
 		return
 	}
 
+	// 不是专属领取人
+	if err == models.ErrNotRecipient {
+		bot.AnswerCallbackQuery(query, tr(fromID, "lng_chat_not_recipient"), false, "", 0)
+		return
+	}
+
 	// 红包过期
 	if err == models.ErrLuckyMoneydExpired {
 		bot.AnswerCallbackQuery(query, tr(fromID, "lng_chat_expired_say"), false, "", 0)
@@ -128,7 +136,7 @@ func (handler *ReceiveHandler) answerReceiveError(bot *methods.BotExt, query *ty
 
 	logger.Errorf("Failed to receive lucky money, id: %d, user_id: %d, %v",
 		id, fromID, err)
-	bot.AnswerCallbackQuery(query, tr(0, "lng_chat_receive_error"), false, "", 0)
+	bot.AnswerCallbackQuery(query, tr(fromID, "lng_chat_receive_error"), false, "", 0)
 }
 
 // 处理领取红包
@@ -146,7 +154,7 @@ func (handler *ReceiveHandler) handleReceiveLuckyMoney(bot *methods.BotExt, quer
 	luckyMoney, received, err := model.GetLuckyMoney(id)
 	if err != nil {
 		logger.Errorf("Failed to get lucky money, %v", err)
-		bot.AnswerCallbackQuery(query, tr(0, "lng_chat_receive_error"), false, "", 0)
+		bot.AnswerCallbackQuery(query, tr(fromID, "lng_chat_receive_error"), false, "", 0)
 		return
 	}
 
@@ -163,43 +171,25 @@ func (handler *ReceiveHandler) handleReceiveLuckyMoney(bot *methods.BotExt, quer
 	}
 
 	// 执行领取红包
-	value, _, err := model.ReceiveLuckyMoney(id, fromID, query.From.FirstName)
+	var userName string
+	if query.From.UserName != nil {
+		userName = *query.From.UserName
+	}
+	value, _, err := model.ReceiveLuckyMoney(id, fromID, userName, query.From.FirstName)
 	if err != nil {
 		handler.answerReceiveError(bot, query, id, err)
 		if err == models.ErrLuckyMoneydExpired {
-			ReplyLuckyMoneyInfo(bot, fromID, *query.InlineMessageID, luckyMoney, received, true)
+			ReplyLuckyMoneyInfo(bot, *query.InlineMessageID, luckyMoney, received, true)
 		}
 		return
 	}
 	logger.Warnf("Receive lucky money, id: %d, user_id: %d, value: %s", id, fromID, value.String())
 
-	// 更新资产信息
-	accountModel := models.AccountModel{}
-	_, toAccount, err := accountModel.TransferFromLockAccount(luckyMoney.SenderID, fromID,
-		luckyMoney.Asset, value)
-	if err != nil {
-		logger.Fatalf("Failed to transfer from lock account, from: %d, to: %d, asset: %s, amount: %s, %v",
-			fromID, fromID, luckyMoney.Asset, value.String(), err)
-		return
-	}
-
-	// 插入账户记录
-	versionModel := models.AccountVersionModel{}
-	versionModel.InsertVersion(fromID, &models.Version{
-		Symbol:          luckyMoney.Asset,
-		Balance:         value,
-		Amount:          toAccount.Amount,
-		Reason:          models.ReasonReceive,
-		RefLuckyMoneyID: &luckyMoney.ID,
-		RefUserID:       &luckyMoney.SenderID,
-		RefUserName:     &luckyMoney.SenderName,
-	})
-
 	// 发送领取通知
-	alert := tr(0, "lng_chat_receive_success")
+	alert := tr(fromID, "lng_chat_receive_success")
 	alert = fmt.Sprintf(alert, value.String(), luckyMoney.Asset, bot.UserName)
 	bot.AnswerCallbackQuery(query, alert, true, "", 0)
 
 	// 回复红包信息
-	ReplyLuckyMoneyInfo(bot, fromID, *query.InlineMessageID, luckyMoney, received+1, false)
+	ReplyLuckyMoneyInfo(bot, *query.InlineMessageID, luckyMoney, received+1, false)
 }
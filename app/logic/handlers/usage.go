@@ -34,7 +34,7 @@ func (*UsageHandler) Handle(bot *methods.BotExt, r *history.History, update *typ
 	reply := tr(fromID, "lng_usage_say")
 	version := fmt.Sprintf("Version: %s", app.VERSION)
 	github := fmt.Sprintf("Fork from Github: [%s](%s)", app.GITHUB, app.GITHUB)
-	reply = fmt.Sprintf("%s\n\n%s\n%s", fmt.Sprintf(reply, serveCfg.Name, supportStaff), version, github)
+	reply = fmt.Sprintf("%s\n\n%s\n%s", fmt.Sprintf(reply, bot.FirstName, supportStaff), version, github)
 
 	bot.AnswerCallbackQuery(update.CallbackQuery, "", false, "", 0)
 	bot.EditMessageReplyMarkupDisableWebPagePreview(update.CallbackQuery.Message, reply, true, markup)
@@ -2,14 +2,93 @@ package utils
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/zhangpanyi/basebot/logger"
 	"luckybot/app/config"
 	"luckybot/app/storage/models"
 )
 
+// 语言缓存
+const (
+	languageCacheTTL  = time.Minute // 缓存时间
+	languageCacheSize = 10000       // 缓存上限
+)
+
+type languageEntry struct {
+	code   string
+	expire time.Time
+}
+
+var languageLock sync.Mutex
+var languageCache = make(map[int64]languageEntry)
+
 // 语言翻译
 func Tr(userID int64, key string) string {
-	return config.GetLanguge().Value("zh_CN", key)
+	return config.GetLanguge().Value(GetLanguage(userID), key)
+}
+
+// 获取用户语言
+// 优先使用用户选择的语言，其次匹配电报语言代码，最后使用默认语言
+func GetLanguage(userID int64) string {
+	now := time.Now()
+	languageLock.Lock()
+	entry, ok := languageCache[userID]
+	languageLock.Unlock()
+	if ok && now.Before(entry.expire) {
+		return entry.code
+	}
+
+	languges := config.GetLanguge()
+	code := languges.Default()
+	if userID != 0 {
+		model := models.SubscriberModel{}
+		subscriber, err := model.GetSubscriber(userID)
+		if err == nil {
+			code = languges.Effective(subscriber.Language, subscriber.LanguageCode)
+		} else if err != models.ErrSubscriberNotFound {
+			logger.Warnf("Failed to get subscriber language, user: %d, %v", userID, err)
+		}
+	}
+
+	languageLock.Lock()
+	defer languageLock.Unlock()
+	if len(languageCache) >= languageCacheSize {
+		for id, entry := range languageCache {
+			if now.After(entry.expire) {
+				delete(languageCache, id)
+			}
+		}
+		if len(languageCache) >= languageCacheSize {
+			languageCache = make(map[int64]languageEntry)
+		}
+	}
+	languageCache[userID] = languageEntry{code: code, expire: now.Add(languageCacheTTL)}
+	return code
+}
+
+// 设置用户语言
+func SetLanguage(userID int64, code string) error {
+	model := models.SubscriberModel{}
+	if err := model.SetLanguage(userID, code); err != nil {
+		return err
+	}
+
+	languageLock.Lock()
+	defer languageLock.Unlock()
+	languageCache[userID] = languageEntry{code: code, expire: time.Now().Add(languageCacheTTL)}
+	return nil
+}
+
+// 获取资产名称
+func assetName(symbol string) string {
+	serveCfg := config.GetServe()
+	asset, ok := serveCfg.GetAsset(symbol)
+	if !ok {
+		return symbol
+	}
+	return asset.Name
 }
 
 // 生成历史内容
@@ -25,6 +104,11 @@ func MakeHistoryMessage(fromID int64, version *models.Version) string {
 		message := Tr(fromID, "lng_history_receive")
 		return fmt.Sprintf(message, *version.RefUserName,
 			*version.RefUserID, *version.RefLuckyMoneyID, version.Balance.String(), version.Symbol)
+	case models.ReasonClaimed:
+		// 红包被领取
+		message := Tr(fromID, "lng_history_claimed")
+		return fmt.Sprintf(message, *version.RefUserName,
+			*version.RefUserID, *version.RefLuckyMoneyID, version.Locked.Abs().String(), version.Symbol)
 	case models.ReasonSystem:
 		// 系统发放
 		message := Tr(fromID, "lng_history_system")
@@ -33,7 +117,7 @@ func MakeHistoryMessage(fromID int64, version *models.Version) string {
 		// 退还红包
 		message := Tr(fromID, "lng_history_giveback")
 		return fmt.Sprintf(message, *version.RefLuckyMoneyID,
-			version.Locked.Abs(version.Locked).String(), version.Symbol)
+			version.Locked.Abs().String(), version.Symbol)
 	case models.ReasonDeposit:
 		// 充值代币
 		message := Tr(fromID, "lng_history_deposit")
@@ -41,22 +125,24 @@ func MakeHistoryMessage(fromID int64, version *models.Version) string {
 			*version.RefBlockHeight, *version.RefTxID)
 	case models.ReasonWithdraw:
 		// 正在提现
-		serverCfg := config.GetServe()
 		message := Tr(fromID, "lng_history_withdraw")
-		return fmt.Sprintf(message, version.Locked.String(), version.Symbol, serverCfg.Name,
+		return fmt.Sprintf(message, version.Locked.String(), version.Symbol, assetName(version.Symbol),
 			*version.RefAddress, version.Fee.String(), version.Symbol)
 	case models.ReasonWithdrawFailure:
 		// 提现失败
-		serverCfg := config.GetServe()
 		message := Tr(fromID, "lng_history_withdraw_failure")
-		return fmt.Sprintf(message, version.Locked.Abs(version.Locked).String(), version.Symbol,
-			serverCfg.Name, *version.RefAddress)
+		return fmt.Sprintf(message, version.Locked.Abs().String(), version.Symbol,
+			assetName(version.Symbol), *version.RefAddress)
+	case models.ReasonWithdrawRejected:
+		// 提现被拒绝
+		message := Tr(fromID, "lng_history_withdraw_rejected")
+		return fmt.Sprintf(message, version.Locked.Abs().String(), version.Symbol,
+			assetName(version.Symbol), *version.RefAddress)
 	case models.ReasonWithdrawSuccess:
 		// 提现成功
-		serverCfg := config.GetServe()
 		message := Tr(fromID, "lng_history_withdraw_success")
-		return fmt.Sprintf(message, version.Locked.Abs(version.Locked).String(), version.Symbol,
-			serverCfg.Name, *version.RefAddress, *version.RefTxID)
+		return fmt.Sprintf(message, version.Locked.Abs().String(), version.Symbol,
+			assetName(version.Symbol), *version.RefAddress, *version.RefTxID)
 	}
 	return ""
 }
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"math/big"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/zhangpanyi/basebot/history"
 	"github.com/zhangpanyi/basebot/logger"
@@ -13,33 +17,57 @@ import (
 	"github.com/zhangpanyi/basebot/telegram/types"
 	"luckybot/app/config"
 	"luckybot/app/fmath"
-	"luckybot/app/future"
 	"luckybot/app/logic/scriptengine"
+	"luckybot/app/logic/withdraw"
 	"luckybot/app/storage/models"
 )
 
+// 匹配资产
+var reMathWithdrawAsset *regexp.Regexp
+
 // 匹配金额
 var reMathWithdrawAmount *regexp.Regexp
 
-// 匹配账户
-var reMathWithdrawAccout *regexp.Regexp
+// 匹配地址簿
+var reMathWithdrawBook *regexp.Regexp
+
+// 匹配草稿
+var reMathWithdrawDraft *regexp.Regexp
+
+// 匹配跳过备注
+var reMathWithdrawSkipMemo *regexp.Regexp
 
 // 匹配提交
 var reMathWithdrawSubmit *regexp.Regexp
 
 func init() {
 	var err error
-	reMathWithdrawAmount, err = regexp.Compile("^/withdraw/([0-9]+\\.?[0-9]*)/$")
+	reMathWithdrawAsset, err = regexp.Compile("^/withdraw/(\\w+)/$")
+	if err != nil {
+		panic(err)
+	}
+
+	reMathWithdrawAmount, err = regexp.Compile("^/withdraw/(\\w+)/([0-9]+\\.?[0-9]*)/$")
+	if err != nil {
+		panic(err)
+	}
+
+	reMathWithdrawBook, err = regexp.Compile("^/withdraw/(\\w+)/([0-9]+\\.?[0-9]*)/book/([0-9]+)/$")
 	if err != nil {
 		panic(err)
 	}
 
-	reMathWithdrawAccout, err = regexp.Compile("^/withdraw/([0-9]+\\.?[0-9]*)/(\\w+)/$")
+	reMathWithdrawDraft, err = regexp.Compile("^/withdraw/draft/([0-9a-f]+)/$")
 	if err != nil {
 		panic(err)
 	}
 
-	reMathWithdrawSubmit, err = regexp.Compile("^/withdraw/([0-9]+\\.?[0-9]*)/([\\w|-]+)/submit/$")
+	reMathWithdrawSkipMemo, err = regexp.Compile("^/withdraw/draft/([0-9a-f]+)/skip/$")
+	if err != nil {
+		panic(err)
+	}
+
+	reMathWithdrawSubmit, err = regexp.Compile("^/withdraw/draft/([0-9a-f]+)/submit/$")
 	if err != nil {
 		panic(err)
 	}
@@ -51,47 +79,229 @@ type WithdrawHandler struct {
 
 // 取款信息
 type withdrawInfo struct {
-	account string  // 账户名
-	amount  float64 // 资产数量
+	asset   config.Asset  // 资产类型
+	account string        // 账户名
+	memo    string        // 备注信息
+	amount  fmath.Decimal // 资产数量
+}
+
+// 提现草稿
+type withdrawDraft struct {
+	id      string        // 草稿ID
+	symbol  string        // 代币符号
+	account string        // 账户名
+	memo    string        // 备注信息
+	amount  fmath.Decimal // 资产数量
+	expire  time.Time     // 过期时间
+}
+
+// 最大备注长度
+const MaxWithdrawMemoLen = 128
+
+// 草稿有效期
+const withdrawDraftTTL = time.Minute * 30
+
+var draftMutex sync.Mutex
+var withdrawDrafts = make(map[int64]withdrawDraft)
+
+// 保存提现草稿
+// 地址和备注可能超出回调数据长度限制，填写地址后的提现信息保存在服务端，
+// 回调数据中只记录随机生成的草稿ID，服务重启后旧按钮不会匹配新草稿，
+// 每个用户只保留最新的草稿，过期草稿在保存时清理
+func saveWithdrawDraft(userID int64, info *withdrawInfo) string {
+	token := make([]byte, 8)
+	rand.Read(token)
+	id := hex.EncodeToString(token)
+
+	now := time.Now()
+	draftMutex.Lock()
+	defer draftMutex.Unlock()
+	for key, draft := range withdrawDrafts {
+		if now.After(draft.expire) {
+			delete(withdrawDrafts, key)
+		}
+	}
+	withdrawDrafts[userID] = withdrawDraft{
+		id:      id,
+		symbol:  info.asset.Symbol,
+		account: info.account,
+		memo:    info.memo,
+		amount:  info.amount,
+		expire:  now.Add(withdrawDraftTTL),
+	}
+	return id
+}
+
+// 获取提现草稿
+func getWithdrawDraft(userID int64, id string) (withdrawDraft, bool) {
+	draftMutex.Lock()
+	defer draftMutex.Unlock()
+	draft, ok := withdrawDrafts[userID]
+	if !ok || draft.id != id || time.Now().After(draft.expire) {
+		return withdrawDraft{}, false
+	}
+	return draft, true
+}
+
+// 删除提现草稿
+func removeWithdrawDraft(userID int64, id string) bool {
+	draftMutex.Lock()
+	defer draftMutex.Unlock()
+	draft, ok := withdrawDrafts[userID]
+	if !ok || draft.id != id {
+		return false
+	}
+	delete(withdrawDrafts, userID)
+	return true
+}
+
+// 生成草稿回调数据
+func makeWithdrawDraftData(id string) string {
+	return "/withdraw/draft/" + id + "/"
+}
+
+// 生成输入账户名回调数据
+func makeWithdrawAccountData(info *withdrawInfo) string {
+	return fmt.Sprintf("/withdraw/%s/%s/", info.asset.Symbol, info.amount.String())
 }
 
 // 消息处理
 func (handler *WithdrawHandler) Handle(bot *methods.BotExt, r *history.History, update *types.Update) {
-	// 回复输入金额
-	info := new(withdrawInfo)
+	// 回复选择资产
 	data := update.CallbackQuery.Data
 	if data == "/withdraw/" {
-		handler.replyEnterWithdrawAmount(bot, r, info, update)
+		r.Clear()
+		handler.replyChooseAsset(bot, update.CallbackQuery)
+		return
 	}
 
-	// 处理输入账户名
-	result := reMathWithdrawAmount.FindStringSubmatch(data)
+	// 回复输入备注
+	// 草稿路径需要先于资产路径匹配
+	var ok bool
+	info := new(withdrawInfo)
+	result := reMathWithdrawDraft.FindStringSubmatch(data)
 	if len(result) == 2 {
-		amount, _ := strconv.ParseFloat(result[1], 10)
-		info.amount = amount
-		handler.replyEnterAccout(bot, r, info, update, true)
+		if !handler.parseDraft(bot, info, update.CallbackQuery, result[1]) {
+			return
+		}
+		handler.replyEnterMemo(bot, r, info, update, true)
 		return
 	}
 
-	// 处理提现总览
-	result = reMathWithdrawAccout.FindStringSubmatch(data)
-	if len(result) == 3 {
-		amount, _ := strconv.ParseFloat(result[1], 10)
-		info.amount = amount
-		info.account = result[2]
-		handler.replyWithdrawOverview(bot, r, info, update, true)
+	// 处理跳过备注
+	result = reMathWithdrawSkipMemo.FindStringSubmatch(data)
+	if len(result) == 2 {
+		if !handler.parseDraft(bot, info, update.CallbackQuery, result[1]) {
+			return
+		}
+		r.Clear()
+		info.memo = ""
+		id := saveWithdrawDraft(update.CallbackQuery.From.ID, info)
+		handler.replyWithdrawOverview(bot, r, info, update, id, makeWithdrawDraftData(id), true)
 		return
 	}
 
 	// 处理提现请求
 	result = reMathWithdrawSubmit.FindStringSubmatch(data)
-	if len(result) == 3 {
-		amount, _ := strconv.ParseFloat(result[1], 10)
-		info.amount = amount
-		info.account = result[2]
+	if len(result) == 2 {
+		if !handler.parseDraft(bot, info, update.CallbackQuery, result[1]) {
+			return
+		}
+		if !removeWithdrawDraft(update.CallbackQuery.From.ID, result[1]) {
+			return
+		}
 		handler.handleWithdraw(bot, r, info, update.CallbackQuery)
 		return
 	}
+
+	// 回复输入金额
+	serveCfg := config.GetServe()
+	result = reMathWithdrawAsset.FindStringSubmatch(data)
+	if len(result) == 2 {
+		info.asset, ok = serveCfg.GetAsset(result[1])
+		if !ok {
+			return
+		}
+		handler.replyEnterWithdrawAmount(bot, r, info, update)
+		return
+	}
+
+	// 处理输入账户名
+	result = reMathWithdrawAmount.FindStringSubmatch(data)
+	if len(result) == 3 {
+		info.asset, ok = serveCfg.GetAsset(result[1])
+		if !ok {
+			return
+		}
+		info.amount, ok = fmath.Parse(result[2], info.asset.Precision)
+		if !ok {
+			return
+		}
+		handler.replyEnterAccout(bot, r, info, update, true)
+		return
+	}
+
+	// 处理选择地址
+	result = reMathWithdrawBook.FindStringSubmatch(data)
+	if len(result) == 4 {
+		info.asset, ok = serveCfg.GetAsset(result[1])
+		if !ok {
+			return
+		}
+		info.amount, ok = fmath.Parse(result[2], info.asset.Precision)
+		if !ok {
+			return
+		}
+		if !handler.parseAddress(bot, info, update.CallbackQuery, result[3]) {
+			return
+		}
+		r.Clear()
+		id := saveWithdrawDraft(update.CallbackQuery.From.ID, info)
+		handler.replyWithdrawOverview(bot, r, info, update, id, makeWithdrawAccountData(info), true)
+		return
+	}
+}
+
+// 解析提现草稿
+func (handler *WithdrawHandler) parseDraft(bot *methods.BotExt, info *withdrawInfo,
+	query *types.CallbackQuery, id string) bool {
+
+	fromID := query.From.ID
+	draft, ok := getWithdrawDraft(fromID, id)
+	if ok {
+		serveCfg := config.GetServe()
+		info.asset, ok = serveCfg.GetAsset(draft.symbol)
+	}
+	if !ok {
+		bot.AnswerCallbackQuery(query, tr(fromID, "lng_withdraw_memo_expired"), false, "", 0)
+		return false
+	}
+	info.account = draft.account
+	info.memo = draft.memo
+	info.amount = draft.amount
+	return true
+}
+
+// 解析地址簿地址
+// 回调数据中只记录地址簿条目ID，地址和备注从地址簿读取，地址在保存时已经校验
+func (handler *WithdrawHandler) parseAddress(bot *methods.BotExt, info *withdrawInfo,
+	query *types.CallbackQuery, s string) bool {
+
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	fromID := query.From.ID
+	model := models.AddressBookModel{}
+	entry, err := model.GetAddress(fromID, id)
+	if err != nil || entry.Symbol != info.asset.Symbol {
+		bot.AnswerCallbackQuery(query, tr(fromID, "lng_address_not_found"), false, "", 0)
+		return false
+	}
+	info.account = entry.Address
+	info.memo = entry.Memo
+	return true
 }
 
 // 消息路由
@@ -99,6 +309,14 @@ func (handler *WithdrawHandler) route(bot *methods.BotExt, query *types.Callback
 	return nil
 }
 
+// 回复选择资产
+func (handler *WithdrawHandler) replyChooseAsset(bot *methods.BotExt, query *types.CallbackQuery) {
+	fromID := query.From.ID
+	markup := makeAssetMenus(fromID, query.Data, "/main/")
+	bot.AnswerCallbackQuery(query, "", false, "", 0)
+	bot.EditMessageReplyMarkup(query.Message, tr(fromID, "lng_withdraw_choose_asset"), true, markup)
+}
+
 // 处理输入提现金额
 func (handler *WithdrawHandler) handleEnterWithdrawAmount(bot *methods.BotExt, r *history.History, info *withdrawInfo,
 	update *types.Update, amount string) {
@@ -112,7 +330,7 @@ func (handler *WithdrawHandler) handleEnterWithdrawAmount(bot *methods.BotExt, r
 		menus := [...]methods.InlineKeyboardButton{
 			methods.InlineKeyboardButton{
 				Text:         tr(fromID, "lng_back_superior"),
-				CallbackData: "/main/",
+				CallbackData: backSuperior(data),
 			},
 		}
 		bot.AnswerCallbackQuery(query, "", false, "", 0)
@@ -121,37 +339,36 @@ func (handler *WithdrawHandler) handleEnterWithdrawAmount(bot *methods.BotExt, r
 	}
 
 	// 获取账户余额
-	balance := big.NewFloat(0)
-	serverCfg := config.GetServe()
-	model := models.AccountModel{}
-	account, err := model.GetAccount(fromID, serverCfg.Symbol)
-	if err == nil {
-		balance = account.Amount
-	}
+	symbol := info.asset.Symbol
+	balance, _ := getUserBalance(fromID, symbol)
 
 	// 检查输入金额
-	result := strings.Split(amount, ".")
-	fee := big.NewFloat(serverCfg.WithdrawFee)
-	if len(result) == 2 && len(result[1]) > 2 {
+	fee := info.asset.Fee()
+	fAmount, ok := fmath.Parse(amount, info.asset.Precision)
+	if !ok || fAmount.Sign() <= 0 {
 		reply := tr(fromID, "lng_withdraw_amount_not_enough")
-		handlerError(fmt.Sprintf(reply, balance.String(),
-			serverCfg.Symbol, fee.String(), serverCfg.Symbol))
+		handlerError(fmt.Sprintf(reply, info.asset.Precision, balance.String(),
+			symbol, fee.String(), symbol))
 		return
 	}
 
-	fAmount, err := strconv.ParseFloat(amount, 10)
-	if err != nil {
-		reply := tr(fromID, "lng_withdraw_amount_not_enough")
+	// 检查用户余额
+	if balance.Cmp(fAmount.Add(fee)) == -1 {
+		reply := tr(fromID, "lng_withdraw_amount_error")
 		handlerError(fmt.Sprintf(reply, balance.String(),
-			serverCfg.Symbol, fee.String(), serverCfg.Symbol))
+			symbol, fee.String(), symbol))
 		return
 	}
 
-	// 检查用户余额
-	if err != nil || account.Amount.Cmp(fmath.Add(big.NewFloat(fAmount), fee)) == -1 {
-		reply := tr(fromID, "lng_withdraw_amount_error")
-		handlerError(fmt.Sprintf(reply, balance.String(),
-			serverCfg.Symbol, fee.String(), serverCfg.Symbol))
+	// 检查提现限制
+	usage, err := withdraw.GetUsage(fromID, info.asset)
+	if err == nil {
+		err = withdraw.CheckLimits(info.asset, usage, fAmount)
+	}
+	if err != nil {
+		logger.Infof("Withdraw limited, user: %d, asset: %s, amount: %s, %v",
+			fromID, symbol, fAmount.String(), err)
+		handlerError(makeWithdrawLimitReply(fromID, info.asset, err))
 		return
 	}
 
@@ -180,29 +397,24 @@ func (handler *WithdrawHandler) replyEnterWithdrawAmount(bot *methods.BotExt, r
 	menus := [...]methods.InlineKeyboardButton{
 		methods.InlineKeyboardButton{
 			Text:         tr(fromID, "lng_back_superior"),
-			CallbackData: "/main/",
+			CallbackData: backSuperior(query.Data),
 		},
 	}
 	markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
 
 	// 获取账户余额
-	balance := big.NewFloat(0)
-	serverCfg := config.GetServe()
-	model := models.AccountModel{}
-	account, err := model.GetAccount(fromID, serverCfg.Symbol)
-	if err == nil {
-		balance = account.Amount
-	}
+	symbol := info.asset.Symbol
+	balance, _ := getUserBalance(fromID, symbol)
 
 	// 回复提现操作提示
-	fee := big.NewFloat(serverCfg.WithdrawFee)
+	fee := info.asset.Fee()
 	reply := tr(fromID, "lng_withdraw_enter_amount")
-	reply = fmt.Sprintf(reply, balance.String(),
-		serverCfg.Symbol, fee.String(), serverCfg.Symbol)
+	reply = fmt.Sprintf(reply, info.asset.Precision, balance.String(),
+		symbol, fee.String(), symbol)
 	bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
 
 	answer := tr(fromID, "lng_withdraw_enter_amount_answer")
-	answer = fmt.Sprintf(answer, serverCfg.Symbol)
+	answer = fmt.Sprintf(answer, symbol)
 	bot.AnswerCallbackQuery(query, answer, false, "", 0)
 }
 
@@ -228,7 +440,7 @@ func (handler *WithdrawHandler) handleEnterWithdrawAccout(bot *methods.BotExt, r
 	}
 
 	// 检查帐号合法
-	if !scriptengine.Engine.ValidAddress(account) {
+	if !scriptengine.Engine.ValidAddress(account, info.asset.Symbol) {
 		handlerError(tr(fromID, "lng_withdraw_account_error"))
 		return
 	}
@@ -236,8 +448,9 @@ func (handler *WithdrawHandler) handleEnterWithdrawAccout(bot *methods.BotExt, r
 	// 更新下个操作状态
 	r.Clear()
 	info.account = account
-	update.CallbackQuery.Data = data + account + "/"
-	handler.replyWithdrawOverview(bot, r, info, update, false)
+	id := saveWithdrawDraft(fromID, info)
+	update.CallbackQuery.Data = makeWithdrawDraftData(id)
+	handler.replyEnterMemo(bot, r, info, update, false)
 }
 
 // 回复输入账户名
@@ -251,22 +464,36 @@ func (handler *WithdrawHandler) replyEnterAccout(bot *methods.BotExt, r *history
 		return
 	}
 
-	// 生成菜单列表
+	// 生成地址簿菜单
 	query := update.CallbackQuery
 	fromID := query.From.ID
+	model := models.AddressBookModel{}
+	entries, err := model.GetAddresses(fromID, info.asset.Symbol)
+	if err != nil {
+		logger.Warnf("Failed to get address book, user: %d, %v", fromID, err)
+	}
+	bookMenus := make([]methods.InlineKeyboardButton, 0, len(entries))
+	for _, entry := range entries {
+		bookMenus = append(bookMenus, methods.InlineKeyboardButton{
+			Text:         entry.Label,
+			CallbackData: fmt.Sprintf("%sbook/%d/", query.Data, entry.ID),
+		})
+	}
+
+	// 生成菜单列表
 	menus := [...]methods.InlineKeyboardButton{
 		methods.InlineKeyboardButton{
 			Text:         tr(fromID, "lng_back_superior"),
 			CallbackData: backSuperior(query.Data),
 		},
 	}
-	markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
+	markup := methods.MakeInlineKeyboardMarkupAuto(bookMenus, 2)
+	markup = markup.Merge(methods.MakeInlineKeyboardMarkupAuto(menus[:], 1))
 
 	// 回复请求结果
 	r.Clear().Push(update)
-	serverCfg := config.GetServe()
 	reply := tr(fromID, "lng_withdraw_enter_account")
-	reply = fmt.Sprintf(reply, big.NewFloat(info.amount).String(), serverCfg.Symbol, serverCfg.Name)
+	reply = fmt.Sprintf(reply, info.amount.String(), info.asset.Symbol, info.asset.Name)
 	if !edit {
 		bot.SendMessage(fromID, reply, true, markup)
 	} else {
@@ -274,12 +501,80 @@ func (handler *WithdrawHandler) replyEnterAccout(bot *methods.BotExt, r *history
 	}
 
 	answer := tr(fromID, "lng_withdraw_enter_account_answer")
-	bot.AnswerCallbackQuery(query, fmt.Sprintf(answer, info.account), false, "", 0)
+	bot.AnswerCallbackQuery(query, fmt.Sprintf(answer, info.asset.Name), false, "", 0)
+}
+
+// 处理输入备注
+func (handler *WithdrawHandler) handleEnterMemo(bot *methods.BotExt, r *history.History,
+	info *withdrawInfo, update *types.Update, memo string) {
+
+	// 处理错误
+	query := update.CallbackQuery
+	fromID := query.From.ID
+	memo = strings.TrimSpace(memo)
+	if utf8.RuneCountInString(memo) > MaxWithdrawMemoLen {
+		r.Pop()
+		menus := [...]methods.InlineKeyboardButton{
+			methods.InlineKeyboardButton{
+				Text:         tr(fromID, "lng_back_superior"),
+				CallbackData: makeWithdrawAccountData(info),
+			},
+		}
+		bot.AnswerCallbackQuery(query, "", false, "", 0)
+		markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
+		reply := fmt.Sprintf(tr(fromID, "lng_withdraw_memo_error"), MaxWithdrawMemoLen)
+		bot.SendMessage(fromID, reply, true, markup)
+		return
+	}
+
+	// 更新下个操作状态
+	r.Clear()
+	info.memo = memo
+	id := saveWithdrawDraft(fromID, info)
+	handler.replyWithdrawOverview(bot, r, info, update, id, makeWithdrawDraftData(id), false)
+}
+
+// 回复输入备注
+func (handler *WithdrawHandler) replyEnterMemo(bot *methods.BotExt, r *history.History, info *withdrawInfo,
+	update *types.Update, edit bool) {
+
+	// 处理输入备注
+	back, err := r.Back()
+	if err == nil && back.Message != nil {
+		handler.handleEnterMemo(bot, r, info, update, back.Message.Text)
+		return
+	}
+
+	// 生成菜单列表
+	query := update.CallbackQuery
+	fromID := query.From.ID
+	menus := [...]methods.InlineKeyboardButton{
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_withdraw_skip_memo"),
+			CallbackData: query.Data + "skip/",
+		},
+		methods.InlineKeyboardButton{
+			Text:         tr(fromID, "lng_back_superior"),
+			CallbackData: makeWithdrawAccountData(info),
+		},
+	}
+	markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
+
+	// 回复请求结果
+	r.Clear().Push(update)
+	reply := tr(fromID, "lng_withdraw_enter_memo")
+	reply = fmt.Sprintf(reply, info.amount.String(), info.asset.Symbol, info.account)
+	if !edit {
+		bot.SendMessage(fromID, reply, true, markup)
+	} else {
+		bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
+	}
+	bot.AnswerCallbackQuery(query, tr(fromID, "lng_withdraw_enter_memo_answer"), false, "", 0)
 }
 
 // 处理提现概览
 func (handler *WithdrawHandler) replyWithdrawOverview(bot *methods.BotExt, r *history.History, info *withdrawInfo,
-	update *types.Update, edit bool) {
+	update *types.Update, id, back string, edit bool) {
 
 	// 应答请求
 	fromID := update.CallbackQuery.From.ID
@@ -287,22 +582,25 @@ func (handler *WithdrawHandler) replyWithdrawOverview(bot *methods.BotExt, r *hi
 	bot.AnswerCallbackQuery(update.CallbackQuery, answer, false, "", 0)
 
 	// 格式化信息
-	serverCfg := config.GetServe()
-	amount := big.NewFloat(info.amount)
-	fee := big.NewFloat(serverCfg.WithdrawFee)
+	symbol := info.asset.Symbol
+	fee := info.asset.Fee()
+	memo := info.memo
+	if len(memo) == 0 {
+		memo = tr(fromID, "lng_address_no_memo")
+	}
 	reply := tr(fromID, "lng_withdraw_overview")
-	reply = fmt.Sprintf(reply, info.account, amount.String(), serverCfg.Symbol,
-		amount.String(), fee.String(), serverCfg.Symbol, fee.String(), serverCfg.Symbol)
+	reply = fmt.Sprintf(reply, info.account, memo, info.amount.String(), symbol,
+		info.amount.String(), fee.String(), symbol, fee.String(), symbol)
 
 	// 生成菜单按钮
 	menus := [...]methods.InlineKeyboardButton{
 		methods.InlineKeyboardButton{
 			Text:         tr(fromID, "lng_withdraw_submit"),
-			CallbackData: update.CallbackQuery.Data + "submit/",
+			CallbackData: makeWithdrawDraftData(id) + "submit/",
 		},
 		methods.InlineKeyboardButton{
 			Text:         tr(fromID, "lng_back_superior"),
-			CallbackData: backSuperior(update.CallbackQuery.Data),
+			CallbackData: back,
 		},
 	}
 	markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
@@ -329,96 +627,64 @@ func (handler *WithdrawHandler) handleWithdraw(bot *methods.BotExt, r *history.H
 	}
 	markup := methods.MakeInlineKeyboardMarkupAuto(menus[:], 1)
 
-	// 获取手续费
-	serverCfg := config.GetServe()
-	fee := big.NewFloat(serverCfg.WithdrawFee)
-
-	// 扣除余额
-	model := models.AccountModel{}
-	amount := big.NewFloat(info.amount)
-	account, err := model.LockAccount(fromID, serverCfg.Symbol, fmath.Add(amount, fee))
+	// 提交提现
+	symbol := info.asset.Symbol
+	amount := info.amount
+	record, err := withdraw.Submit(fromID, info.asset, info.account, info.memo, amount)
 	if err != nil {
-		logger.Warnf("Failed to withdraw, user: %d, asset: %s, amount: %s, fee: %s, %v",
-			fromID, serverCfg.Symbol, amount.String(), fee.String(), err)
-		reply := tr(fromID, "lng_withdraw_not_enough")
+		logger.Warnf("Failed to withdraw, user: %d, asset: %s, amount: %s, %v",
+			fromID, symbol, amount.String(), err)
+		reply := makeWithdrawLimitReply(fromID, info.asset, err)
 		bot.AnswerCallbackQuery(query, reply, false, "", 0)
 		bot.EditMessageReplyMarkup(query.Message, reply, false, markup)
 		return
 	}
-	logger.Errorf("Withdraw submitted, user: %d, asset: %s, amount: %s, fee: %s",
-		fromID, serverCfg.Symbol, amount.String(), fee.String())
+
+	// 等待审核
+	if record.State == models.WithdrawReview {
+		reply := tr(fromID, "lng_withdraw_review")
+		answer := tr(fromID, "lng_withdraw_review_answer")
+		bot.AnswerCallbackQuery(query, answer, false, "", 0)
+		bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
+		return
+	}
 
 	// 提交成功
 	reply := tr(fromID, "lng_withdraw_submit_ok")
 	answer := tr(fromID, "lng_withdraw_submit_ok_answer")
 	bot.AnswerCallbackQuery(query, answer, false, "", 0)
-	bot.EditMessageReplyMarkup(query.Message, reply, true, nil)
-
-	// 记录账户历史
-	versionModel := models.AccountVersionModel{}
-	versionModel.InsertVersion(fromID, &models.Version{
-		Symbol:     serverCfg.Symbol,
-		Locked:     amount,
-		Fee:        fee,
-		Amount:     account.Amount,
-		Reason:     models.ReasonWithdraw,
-		RefAddress: &info.account,
-	})
-
-	// 开始转账提示
-	reply = tr(fromID, "lng_withdraw_agreed")
-	bot.EditMessageReplyMarkup(query.Message, reply, true, nil)
-
-	// 执行提现操作
-	zero := big.NewFloat(0)
-	f := future.Manager.NewFuture()
-	go scriptengine.Engine.OnWithdraw(info.account, serverCfg.Symbol, amount.String(), f.ID())
-	txid, err := f.GetResult()
-	if err != nil {
-		// 解锁资产
-		account, err := model.UnlockAccount(fromID, serverCfg.Symbol, fmath.Add(amount, fee))
-		if err == nil {
-			versionModel.InsertVersion(fromID, &models.Version{
-				Symbol:     serverCfg.Symbol,
-				Locked:     fmath.Sub(zero, amount),
-				Fee:        fee,
-				Amount:     account.Amount,
-				Reason:     models.ReasonWithdrawFailure,
-				RefAddress: &info.account,
-			})
-		} else {
-			logger.Warnf(`Failed to unlock account when withdraw failure, user: %d, asset: %s, \
-			amount: %s, fee: %s, %v`, fromID, serverCfg.Symbol, amount.String(), fee.String(), err)
-		}
-
-		// 返回信息
-		reply := tr(fromID, "lng_withdraw_transfer_error")
-		logger.Warnf("Failed to transfer, user: %d, asset: %s, amount: %s, fee: %s, %v",
-			fromID, serverCfg.Symbol, amount.String(), fee.String(), err)
-		bot.EditMessageReplyMarkup(query.Message, reply, false, markup)
-		return
-	}
+	bot.EditMessageReplyMarkup(query.Message, reply, true, markup)
+}
 
-	// 记录提现成功
-	account, err = model.Withdraw(fromID, serverCfg.Symbol, fmath.Add(amount, fee))
-	if err == nil {
-		versionModel.InsertVersion(fromID, &models.Version{
-			Symbol:     serverCfg.Symbol,
-			Balance:    fmath.Sub(zero, fmath.Add(amount, fee)),
-			Locked:     fmath.Sub(zero, amount),
-			Fee:        fee,
-			Amount:     account.Amount,
-			Reason:     models.ReasonWithdrawSuccess,
-			RefAddress: &info.account,
-			RefTxID:    &txid,
-		})
-	} else {
-		logger.Warnf(`Failed to unlock account when withdraw success, user: %d, asset: %s, \
-		amount: %s, fee: %s, %v`, fromID, serverCfg.Symbol, amount.String(), fee.String(), err)
+// 生成提现限制提示
+func makeWithdrawLimitReply(fromID int64, asset config.Asset, err error) string {
+	symbol := asset.Symbol
+	switch err {
+	case withdraw.ErrBelowMinimum:
+		reply := tr(fromID, "lng_withdraw_limit_minimum")
+		return fmt.Sprintf(reply, asset.MinWithdrawAmount().String(), symbol)
+	case withdraw.ErrDailyCount:
+		reply := tr(fromID, "lng_withdraw_limit_count")
+		return fmt.Sprintf(reply, asset.DailyWithdrawCount)
+	case withdraw.ErrDepositCooldown:
+		reply := tr(fromID, "lng_withdraw_limit_cooldown")
+		return fmt.Sprintf(reply, (asset.DepositCooldown+59)/60)
+	case withdraw.ErrDailyLimit, withdraw.ErrWeeklyLimit:
+		usage, e := withdraw.GetUsage(fromID, asset)
+		if e != nil {
+			return tr(fromID, "lng_withdraw_limit_error")
+		}
+		key, limit, used := "lng_withdraw_limit_daily", asset.DailyLimit(), usage.Daily
+		if err == withdraw.ErrWeeklyLimit {
+			key, limit, used = "lng_withdraw_limit_weekly", asset.WeeklyLimit(), usage.Weekly
+		}
+		remaining := limit.Sub(used)
+		if remaining.Sign() < 0 {
+			remaining = fmath.Zero(asset.Precision)
+		}
+		return fmt.Sprintf(tr(fromID, key), limit.String(), symbol, remaining.String(), symbol)
+	case models.ErrInsufficientAmount, models.ErrNoSuchTypeAccount:
+		return tr(fromID, "lng_withdraw_not_enough")
 	}
-
-	// 返回处理结果
-	reply = tr(fromID, "lng_withdraw_success")
-	bot.EditMessageReplyMarkup(query.Message, fmt.Sprintf(reply, txid), true, markup)
-	return
+	return tr(fromID, "lng_withdraw_limit_error")
 }
@@ -1,6 +1,8 @@
 package logic
 
 import (
+	"strings"
+
 	"github.com/zhangpanyi/basebot/logger"
 	"github.com/zhangpanyi/basebot/telegram/methods"
 	"github.com/zhangpanyi/basebot/telegram/types"
@@ -25,11 +27,27 @@ func NewUpdate(bot *methods.BotExt, update *types.Update) {
 			return
 		}
 
-		// 添加订户
+		// 记录订户活跃
+		from := update.Message.From
 		model := models.SubscriberModel{}
-		model.AddSubscriber(fromID)
+		err := model.Touch(fromID, from.UserName, from.LanguageCode, parseReferral(update.Message.Text))
+		if err != nil {
+			logger.Warnf("Failed to touch subscriber, user: %d, %v", fromID, err)
+		}
 	} else if update.CallbackQuery != nil {
 		fromID = update.CallbackQuery.From.ID
+
+		// 记录订户活跃
+		if update.CallbackQuery.InlineMessageID == nil {
+			var userName string
+			if update.CallbackQuery.From.UserName != nil {
+				userName = *update.CallbackQuery.From.UserName
+			}
+			model := models.SubscriberModel{}
+			if err := model.Touch(fromID, userName, "", ""); err != nil {
+				logger.Warnf("Failed to touch subscriber, user: %d, %v", fromID, err)
+			}
+		}
 	} else {
 		return
 	}
@@ -55,3 +73,19 @@ func NewUpdate(bot *methods.BotExt, update *types.Update) {
 		context.DelRecord(uint32(fromID))
 	}
 }
+
+// 最大推荐来源长度
+const maxReferralLen = 64
+
+// 解析推荐来源
+// 电报深度链接 https://t.me/<bot>?start=<referral> 会发送 "/start <referral>"
+func parseReferral(text string) string {
+	if !strings.HasPrefix(text, "/start ") {
+		return ""
+	}
+	referral := strings.TrimSpace(text[len("/start "):])
+	if len(referral) > maxReferralLen {
+		referral = referral[:maxReferralLen]
+	}
+	return referral
+}
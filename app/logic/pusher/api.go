@@ -1,14 +1,50 @@
 package pusher
 
 import (
+	"github.com/zhangpanyi/basebot/logger"
 	"github.com/zhangpanyi/basebot/telegram/methods"
-	"luckybot/app/logic/botext"
+	"luckybot/app/storage/models"
 )
 
 // 投递消息
+// 消息先写入推送队列，再由推送器异步发送
 func Post(receiver int64, text string, markdownMode bool,
 	markup *methods.InlineKeyboardMarkup) {
-	if gpusher != nil && botext.GetBot() != nil {
-		gpusher.push(botext.GetBot(), receiver, text, markdownMode, markup)
+
+	model := models.PushQueueModel{}
+	_, err := model.Push(&models.PushMessage{
+		Receiver: receiver,
+		Text:     text,
+		Markdown: markdownMode,
+		Markup:   markup,
+	})
+	if err != nil {
+		logger.Warnf("Failed to post message, receiver: %d, %v", receiver, err)
+		return
+	}
+	if gpusher != nil {
+		gpusher.notify()
+	}
+}
+
+// 队列状态
+type QueueStats struct {
+	Pending  int `json:"pending"`  // 待推送数量
+	Inflight int `json:"inflight"` // 推送中数量
+	Dead     int `json:"dead"`     // 死信数量
+}
+
+// 获取队列状态
+func GetQueueStats() (QueueStats, error) {
+	model := models.PushQueueModel{}
+	pending, dead, err := model.GetDepth()
+	if err != nil {
+		return QueueStats{}, err
+	}
+
+	stats := QueueStats{Pending: pending, Dead: dead}
+	if gpusher != nil {
+		stats.Inflight = gpusher.inflightCount()
 	}
+	return stats, nil
 }
@@ -0,0 +1,103 @@
+package pusher
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"github.com/zhangpanyi/basebot/telegram/types"
+	"luckybot/app/storage/models"
+)
+
+// 未返回文件ID
+var ErrNoFileID = errors.New("no file id returned")
+
+// 发送请求
+type sendRequest struct {
+	ChatID      int64                         `json:"chat_id"`                // 聊天ID
+	Text        string                        `json:"text,omitempty"`         // 消息文本
+	Photo       string                        `json:"photo,omitempty"`        // 图片文件ID
+	Document    string                        `json:"document,omitempty"`     // 文档文件ID
+	Caption     string                        `json:"caption,omitempty"`      // 媒体标题
+	ParseMode   string                        `json:"parse_mode,omitempty"`   // 解析模式
+	ReplyMarkup *methods.InlineKeyboardMarkup `json:"reply_markup,omitempty"` // Reply Markup
+}
+
+// 投递消息
+// 根据媒体类型发送文本、图片或文档
+func Deliver(sender *methods.BotExt, msg *models.PushMessage) error {
+	request := sendRequest{
+		ChatID:      msg.Receiver,
+		ParseMode:   msg.GetParseMode(),
+		ReplyMarkup: msg.Markup,
+	}
+
+	method := "sendMessage"
+	switch msg.Media {
+	case models.MediaPhoto:
+		method = "sendPhoto"
+		request.Photo = msg.FileID
+		request.Caption = msg.Text
+	case models.MediaDocument:
+		method = "sendDocument"
+		request.Document = msg.FileID
+		request.Caption = msg.Text
+	default:
+		request.Text = msg.Text
+	}
+	_, err := sender.Call(method, &request)
+	return err
+}
+
+// 上传并投递消息
+// 返回媒体文件ID，之后的消息可以直接使用文件ID发送
+func DeliverFile(sender *methods.BotExt, msg *models.PushMessage, file []byte, filename string) (string, error) {
+	method := "sendDocument"
+	if msg.Media == models.MediaPhoto {
+		method = "sendPhoto"
+	}
+
+	formdata := []methods.Field{
+		methods.Field{Name: "chat_id", Text: strconv.FormatInt(msg.Receiver, 10)},
+		methods.Field{Name: msg.Media, File: file, FileName: filename},
+	}
+	if len(msg.Text) > 0 {
+		formdata = append(formdata, methods.Field{Name: "caption", Text: msg.Text})
+	}
+	if parseMode := msg.GetParseMode(); len(parseMode) > 0 {
+		formdata = append(formdata, methods.Field{Name: "parse_mode", Text: parseMode})
+	}
+	if msg.Markup != nil {
+		jsb, err := msg.Markup.ToJSON()
+		if err != nil {
+			return "", err
+		}
+		formdata = append(formdata, methods.Field{Name: "reply_markup", Text: string(jsb)})
+	}
+
+	res, err := sender.Upload(method, formdata)
+	if err != nil {
+		return "", err
+	}
+	var respone methods.SendMessageResonpe
+	if err = json.Unmarshal(res, &respone); err != nil {
+		return "", err
+	}
+	return fileID(respone.Result)
+}
+
+// 获取文件ID
+// 图片取最大尺寸
+func fileID(message *types.Message) (string, error) {
+	if message == nil {
+		return "", ErrNoFileID
+	}
+	if message.Document != nil {
+		return message.Document.FileID, nil
+	}
+	if len(message.Photo) > 0 {
+		return message.Photo[len(message.Photo)-1].FileID, nil
+	}
+	return "", ErrNoFileID
+}
@@ -1,24 +1,108 @@
 package pusher
 
 import (
+	"regexp"
+	"strconv"
+	"time"
+
 	"github.com/zhangpanyi/basebot/logger"
 	"github.com/zhangpanyi/basebot/telegram/methods"
+	"luckybot/app/storage/models"
 )
 
-// 消息内容
-type message struct {
-	sender       *methods.BotExt               // 发送者
-	receiver     int64                         // 接收者
-	text         string                        // 文本
-	markdownMode bool                          // MarkDown渲染
-	markup       *methods.InlineKeyboardMarkup // Reply Markup
+// 最大尝试次数
+const maxAttempts = 8
+
+// 最大重试间隔
+const maxRetryDelay = time.Hour
+
+// 匹配错误代码
+var reMathErrorCode = regexp.MustCompile(`error code: (\d+)`)
+
+// 获取错误代码
+// 网络错误等没有错误代码时返回0
+func errorCode(err error) int {
+	result := reMathErrorCode.FindStringSubmatch(err.Error())
+	if len(result) != 2 {
+		return 0
+	}
+	code, _ := strconv.Atoi(result[1])
+	return code
+}
+
+// 匹配重试等待
+var reMathRetryAfter = regexp.MustCompile(`retry after (\d+)`)
+
+// 是否被用户屏蔽
+func IsBlocked(err error) bool {
+	return errorCode(err) == 403
+}
+
+// 是否无效请求
+func IsBadRequest(err error) bool {
+	return errorCode(err) == 400
+}
+
+// 获取限流等待时间
+// 没有被限流时返回0
+func RetryAfter(err error) time.Duration {
+	if errorCode(err) != 429 {
+		return 0
+	}
+	result := reMathRetryAfter.FindStringSubmatch(err.Error())
+	if len(result) != 2 {
+		return time.Second
+	}
+	seconds, _ := strconv.Atoi(result[1])
+	return time.Duration(seconds) * time.Second
+}
+
+// 重试间隔
+func retryDelay(attempts int) time.Duration {
+	delay := time.Second * 5
+	for i := 0; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
 }
 
 // 发送消息
-func (msg *message) send() {
-	_, err := msg.sender.SendMessage(msg.receiver, msg.text,
-		msg.markdownMode, msg.markup)
+// 用户屏蔽机器人或请求无效时移入死信，其它错误稍后重试
+func send(sender *methods.BotExt, msg *models.PushMessage) {
+	model := models.PushQueueModel{}
+	err := Deliver(sender, msg)
+	if err == nil {
+		if err = model.Remove(msg.ID); err != nil {
+			logger.Warnf("Failed to remove push message, id: %d, %v", msg.ID, err)
+		}
+		return
+	}
+
+	switch {
+	case IsBlocked(err):
+		logger.Infof("Push receiver blocked, id: %d, receiver: %d, %v", msg.ID, msg.Receiver, err)
+		subscriberModel := models.SubscriberModel{}
+		if e := subscriberModel.SetBlocked(msg.Receiver, true); e != nil {
+			logger.Warnf("Failed to set subscriber blocked, user: %d, %v", msg.Receiver, e)
+		}
+		err = model.DeadLetter(msg.ID, err.Error())
+	case IsBadRequest(err) || msg.Attempts+1 >= maxAttempts:
+		logger.Warnf("Failed to push message, id: %d, receiver: %d, attempts: %d, %v",
+			msg.ID, msg.Receiver, msg.Attempts+1, err)
+		err = model.DeadLetter(msg.ID, err.Error())
+	default:
+		delay := retryDelay(msg.Attempts)
+		if retryAfter := RetryAfter(err); retryAfter > delay {
+			delay = retryAfter
+		}
+		logger.Infof("Failed to push message, retry after %v, id: %d, receiver: %d, %v",
+			delay, msg.ID, msg.Receiver, err)
+		err = model.Retry(msg.ID, time.Now().Add(delay).UTC().Unix(), err.Error())
+	}
 	if err != nil {
-		logger.Warnf("Failed to push message, %v", err)
+		logger.Warnf("Failed to update push message, id: %d, %v", msg.ID, err)
 	}
 }
@@ -1,73 +1,110 @@
 package pusher
 
 import (
-	"container/list"
 	"sync"
+	"time"
 
-	"github.com/zhangpanyi/basebot/telegram/methods"
+	"github.com/zhangpanyi/basebot/logger"
 	"github.com/zhangpanyi/basebot/telegram/updater"
+	"luckybot/app/logic/botext"
+	"luckybot/app/storage/models"
 )
 
 var once sync.Once
 var gpusher *msgPusher
 
+// 最大并发推送数量
+const maxInflight = 64
+
 // 运行推送器
 func ServiceStart(pool *updater.Pool) {
 	once.Do(func() {
 		gpusher = &msgPusher{
-			pool:  pool,
-			queue: list.New(),
-			cond:  sync.NewCond(&sync.Mutex{}),
+			pool:     pool,
+			inflight: make(map[uint64]bool),
+			wakeup:   make(chan struct{}, 1),
 		}
 		go gpusher.loop()
 	})
 }
 
 // 推送器
+// 消息持久化在数据库中，发送成功后移除，服务重启后继续推送
 type msgPusher struct {
-	queue *list.List
-	cond  *sync.Cond
-	pool  *updater.Pool
+	mutex    sync.Mutex
+	pool     *updater.Pool
+	inflight map[uint64]bool
+	wakeup   chan struct{}
 }
 
-// 推送消息
-func (m *msgPusher) push(sender *methods.BotExt, receiver int64, text string,
-	markdownMode bool, markup *methods.InlineKeyboardMarkup) {
-
-	// 构造消息结构
-	msg := message{
-		sender:       sender,
-		receiver:     receiver,
-		text:         text,
-		markdownMode: markdownMode,
-		markup:       markup,
+// 唤醒推送
+func (m *msgPusher) notify() {
+	select {
+	case m.wakeup <- struct{}{}:
+	default:
 	}
-
-	// 添加到推送队列
-	m.cond.L.Lock()
-	isempty := m.queue.Len() == 0
-	m.queue.PushBack(&msg)
-	if isempty && m.queue.Len() == 1 {
-		m.cond.Signal()
-	}
-	m.cond.L.Unlock()
 }
 
 // 事件循环
 func (m *msgPusher) loop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 	for {
-		m.cond.L.Lock()
-		for m.queue.Len() == 0 {
-			m.cond.Wait()
-		}
-		for m.queue.Len() > 0 {
-			element := m.queue.Front()
-			msg, ok := element.Value.(*message)
-			if ok {
-				m.pool.Async(msg.send)
-			}
-			m.queue.Remove(element)
+		select {
+		case <-m.wakeup:
+		case <-ticker.C:
 		}
-		m.cond.L.Unlock()
+		m.dispatch()
+	}
+}
+
+// 分发消息
+func (m *msgPusher) dispatch() {
+	bot := botext.GetBot()
+	if bot == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	limit := maxInflight - len(m.inflight)
+	m.mutex.Unlock()
+	if limit <= 0 {
+		return
 	}
+
+	model := models.PushQueueModel{}
+	messages, err := model.GetDue(time.Now().UTC().Unix(), limit, m.isInflight)
+	if err != nil {
+		logger.Warnf("Failed to get push messages, %v", err)
+		return
+	}
+
+	for _, msg := range messages {
+		m.mutex.Lock()
+		m.inflight[msg.ID] = true
+		m.mutex.Unlock()
+
+		msg := msg
+		m.pool.Async(func() {
+			send(bot, msg)
+			m.mutex.Lock()
+			delete(m.inflight, msg.ID)
+			m.mutex.Unlock()
+			m.notify()
+		})
+	}
+}
+
+// 是否正在推送
+func (m *msgPusher) isInflight(id uint64) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.inflight[id]
+}
+
+// 推送中数量
+func (m *msgPusher) inflightCount() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return len(m.inflight)
 }
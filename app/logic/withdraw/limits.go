@@ -0,0 +1,122 @@
+package withdraw
+
+import (
+	"errors"
+	"time"
+
+	"luckybot/app/config"
+	"luckybot/app/fmath"
+	"luckybot/app/storage/models"
+)
+
+var (
+	// 低于最小提现数量
+	ErrBelowMinimum = errors.New("below minimum withdraw amount")
+	// 超过每日提现上限
+	ErrDailyLimit = errors.New("exceeds daily withdraw limit")
+	// 超过每周提现上限
+	ErrWeeklyLimit = errors.New("exceeds weekly withdraw limit")
+	// 超过每日提现次数
+	ErrDailyCount = errors.New("exceeds daily withdraw count")
+	// 充值冷却中
+	ErrDepositCooldown = errors.New("deposit cooldown")
+)
+
+// 提现用量
+type Usage struct {
+	Daily       fmath.Decimal // 当日提现数量
+	Weekly      fmath.Decimal // 本周提现数量
+	DailyCount  int           // 当日提现次数
+	LastDeposit int64         // 最近充值时间
+}
+
+// 当日开始时间
+func dayStart(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// 本周开始时间(周一)
+func weekStart(now time.Time) time.Time {
+	weekday := (int(now.Weekday()) + 6) % 7
+	return dayStart(now).AddDate(0, 0, -weekday)
+}
+
+// 获取提现用量
+// 根据账户版本统计当日、本周的提现数量和次数，失败或被拒绝的提现不计入
+func GetUsage(userID int64, asset config.Asset) (*Usage, error) {
+	now := time.Now().UTC()
+	today := dayStart(now).Unix()
+	week := weekStart(now).Unix()
+	since := week
+	if cooldown := now.Unix() - int64(asset.DepositCooldown); cooldown < since {
+		since = cooldown
+	}
+
+	model := models.AccountVersionModel{}
+	versions, err := model.GetVersionsSince(userID, asset.Symbol, since)
+	if err != nil {
+		return nil, err
+	}
+
+	// 排除退还的提现
+	refunded := make(map[uint64]bool)
+	for _, version := range versions {
+		if version.RefWithdrawID == nil {
+			continue
+		}
+		if version.Reason == models.ReasonWithdrawFailure || version.Reason == models.ReasonWithdrawRejected {
+			refunded[*version.RefWithdrawID] = true
+		}
+	}
+
+	usage := Usage{
+		Daily:  fmath.Zero(asset.Precision),
+		Weekly: fmath.Zero(asset.Precision),
+	}
+	for _, version := range versions {
+		switch version.Reason {
+		case models.ReasonDeposit:
+			if version.Timestamp > usage.LastDeposit {
+				usage.LastDeposit = version.Timestamp
+			}
+		case models.ReasonWithdraw:
+			if version.Locked == nil || version.Timestamp < week {
+				break
+			}
+			if version.RefWithdrawID != nil && refunded[*version.RefWithdrawID] {
+				break
+			}
+			usage.Weekly = usage.Weekly.Add(*version.Locked)
+			if version.Timestamp >= today {
+				usage.Daily = usage.Daily.Add(*version.Locked)
+				usage.DailyCount++
+			}
+		}
+	}
+	return &usage, nil
+}
+
+// 检查提现限制
+func CheckLimits(asset config.Asset, usage *Usage, amount fmath.Decimal) error {
+	if min := asset.MinWithdrawAmount(); min.Sign() > 0 && amount.Cmp(min) < 0 {
+		return ErrBelowMinimum
+	}
+
+	if asset.DailyWithdrawCount > 0 && usage.DailyCount >= asset.DailyWithdrawCount {
+		return ErrDailyCount
+	}
+
+	if asset.DepositCooldown > 0 &&
+		time.Now().UTC().Unix()-usage.LastDeposit < int64(asset.DepositCooldown) {
+		return ErrDepositCooldown
+	}
+
+	if limit := asset.DailyLimit(); limit.Sign() > 0 && usage.Daily.Add(amount).Cmp(limit) > 0 {
+		return ErrDailyLimit
+	}
+
+	if limit := asset.WeeklyLimit(); limit.Sign() > 0 && usage.Weekly.Add(amount).Cmp(limit) > 0 {
+		return ErrWeeklyLimit
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package withdraw
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/zhangpanyi/basebot/logger"
+	"luckybot/app/config"
+	"luckybot/app/fmath"
+	"luckybot/app/future"
+	"luckybot/app/logic/handlers/utils"
+	"luckybot/app/logic/pusher"
+	"luckybot/app/logic/scriptengine"
+	"luckybot/app/storage/models"
+)
+
+// 提交提现
+// 检查提现限制后锁定资金并持久化提现记录，超过审核阈值时等待人工审核，否则异步交给脚本处理
+func Submit(userID int64, asset config.Asset, address, memo string, amount fmath.Decimal) (*models.Withdraw, error) {
+	// 检查提现限制
+	usage, err := GetUsage(userID, asset)
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckLimits(asset, usage, amount); err != nil {
+		return nil, err
+	}
+
+	// 创建提现记录
+	fee := asset.Fee()
+	review := asset.NeedReview(amount, usage.Daily)
+	model := models.WithdrawModel{}
+	withdraw, err := model.NewWithdraw(&models.Withdraw{
+		UserID:  userID,
+		Symbol:  asset.Symbol,
+		Address: address,
+		Memo:    memo,
+		Amount:  amount,
+		Fee:     fee,
+	}, review)
+	if err != nil {
+		return nil, err
+	}
+
+	if review {
+		logger.Warnf("Withdraw needs review, id: %d, user: %d, asset: %s, amount: %s, fee: %s",
+			withdraw.ID, userID, asset.Symbol, amount.String(), fee.String())
+		return withdraw, nil
+	}
+
+	logger.Warnf("Withdraw submitted, id: %d, user: %d, asset: %s, amount: %s, fee: %s",
+		withdraw.ID, userID, asset.Symbol, amount.String(), fee.String())
+
+	go process(withdraw)
+	return withdraw, nil
+}
+
+// 审核通过
+func Approve(id uint64) (*models.Withdraw, error) {
+	model := models.WithdrawModel{}
+	withdraw, err := model.Approve(id)
+	if err != nil {
+		return nil, err
+	}
+	logger.Warnf("Withdraw approved, id: %d, user: %d, asset: %s, amount: %s",
+		withdraw.ID, withdraw.UserID, withdraw.Symbol, withdraw.Amount.String())
+
+	// 推送审核通知
+	pusher.Post(withdraw.UserID, utils.Tr(withdraw.UserID, "lng_withdraw_agreed"), true, nil)
+
+	go process(withdraw)
+	return withdraw, nil
+}
+
+// 审核拒绝
+// 解锁资金并通知用户
+func Reject(id uint64, reason string) (*models.Withdraw, error) {
+	model := models.WithdrawModel{}
+	withdraw, version, err := model.Reject(id, reason)
+	if err != nil {
+		return nil, err
+	}
+	logger.Warnf("Withdraw rejected, id: %d, user: %d, asset: %s, amount: %s, reason: %s",
+		withdraw.ID, withdraw.UserID, withdraw.Symbol, withdraw.Amount.String(), reason)
+
+	// 推送审核通知
+	pusher.Post(withdraw.UserID, utils.MakeHistoryMessage(withdraw.UserID, version), true, nil)
+	return withdraw, nil
+}
+
+// 恢复提现
+// 服务重启后将未完成的提现重新交给脚本处理，等待审核和超时待确认的提现除外。
+// 已提交脚本的提现可能已经转账，只有脚本按提现ID幂等时才重新提交，否则转为超时待确认
+func Resume() {
+	model := models.WithdrawModel{}
+	withdraws, err := model.GetUnfinished()
+	if err != nil {
+		logger.Errorf("Failed to resume withdraws, %v", err)
+		return
+	}
+
+	idempotent := config.GetServe().WithdrawIdempotent
+	for _, withdraw := range withdraws {
+		if withdraw.State == models.WithdrawReview || withdraw.State == models.WithdrawTimedOut {
+			continue
+		}
+		if withdraw.State == models.WithdrawSubmitted && !idempotent {
+			if _, err := model.SetTimedOut(withdraw.ID, "resumed after restart"); err != nil {
+				logger.Errorf("Failed to set withdraw timed out, id: %d, %v", withdraw.ID, err)
+				continue
+			}
+			logger.Errorf("Withdraw interrupted by restart, needs confirm, id: %d, user: %d, asset: %s, amount: %s, address: %s",
+				withdraw.ID, withdraw.UserID, withdraw.Symbol, withdraw.Amount.String(), withdraw.Address)
+			continue
+		}
+		logger.Infof("Resume withdraw, id: %d, user: %d, asset: %s, amount: %s, state: %d",
+			withdraw.ID, withdraw.UserID, withdraw.Symbol, withdraw.Amount.String(), withdraw.State)
+		go process(withdraw)
+	}
+}
+
+// 处理提现
+func process(withdraw *models.Withdraw) {
+	// 标记已提交
+	model := models.WithdrawModel{}
+	if _, err := model.SetSubmitted(withdraw.ID); err != nil {
+		logger.Warnf("Failed to set withdraw submitted, id: %d, %v", withdraw.ID, err)
+		return
+	}
+
+	// 执行提现操作
+	f, txid, err := transfer(withdraw)
+	if err == future.ErrTimeout {
+		if config.GetServe().WithdrawAutoUnlock {
+			future.Manager.Remove(f.ID())
+			settle(withdraw, txid, err)
+			return
+		}
+		timeout(withdraw, f)
+		return
+	}
+	settle(withdraw, txid, err)
+}
+
+// 处理转账结果
+func settle(withdraw *models.Withdraw, txid string, err error) {
+	if err != nil {
+		logger.Warnf("Failed to transfer, id: %d, user: %d, asset: %s, amount: %s, fee: %s, %v",
+			withdraw.ID, withdraw.UserID, withdraw.Symbol, withdraw.Amount.String(), withdraw.Fee.String(), err)
+		fail(withdraw, err.Error())
+		return
+	}
+
+	// 确认提现成功
+	model := models.WithdrawModel{}
+	_, version, err := model.Confirm(withdraw.ID, txid)
+	if err != nil {
+		logger.Errorf("Failed to confirm withdraw, id: %d, txid: %s, %v", withdraw.ID, txid, err)
+		return
+	}
+	logger.Warnf("Withdraw success, id: %d, user: %d, asset: %s, amount: %s, fee: %s, txid: %s",
+		withdraw.ID, withdraw.UserID, withdraw.Symbol, withdraw.Amount.String(), withdraw.Fee.String(), txid)
+
+	// 推送提现通知
+	pusher.Post(withdraw.UserID, utils.MakeHistoryMessage(withdraw.UserID, version), true, nil)
+}
+
+// 执行转账
+// 脚本声明按提现ID幂等时，等待超时后以相同ID重新调用脚本，否则只调用一次，
+// 超时后Future保持注册，返回超时错误
+func transfer(withdraw *models.Withdraw) (*future.Future, string, error) {
+	serveCfg := config.GetServe()
+	timeout := time.Duration(serveCfg.WithdrawTimeout) * time.Second
+	retries := 0
+	if serveCfg.WithdrawIdempotent {
+		retries = serveCfg.WithdrawRetries
+	}
+
+	id := strconv.FormatUint(withdraw.ID, 10)
+	f := future.Manager.NewFuture(id)
+	for i := 0; ; i++ {
+		go scriptengine.Engine.OnWithdraw(id, withdraw.Address, withdraw.Memo, withdraw.Symbol, withdraw.Amount.String(), f.ID())
+		txid, err := f.GetResult(timeout)
+		if err != future.ErrTimeout || i >= retries {
+			return f, txid, err
+		}
+		logger.Warnf("Withdraw timeout, retry, id: %d, retries: %d", withdraw.ID, i+1)
+	}
+}
+
+// 提现超时
+// 脚本可能已经转账，因此不解锁资金，标记为超时等待人工确认，
+// 之后脚本设置的结果仍会被处理，人工处理后移除Future结束等待
+func timeout(withdraw *models.Withdraw, f *future.Future) {
+	model := models.WithdrawModel{}
+	if _, err := model.SetTimedOut(withdraw.ID, future.ErrTimeout.Error()); err != nil {
+		logger.Errorf("Failed to set withdraw timed out, id: %d, %v", withdraw.ID, err)
+	}
+	logger.Errorf("Withdraw timed out, needs confirm, id: %d, user: %d, asset: %s, amount: %s, address: %s",
+		withdraw.ID, withdraw.UserID, withdraw.Symbol, withdraw.Amount.String(), withdraw.Address)
+
+	go func() {
+		txid, err := f.GetResult(0)
+		if err == future.ErrCanceled {
+			return
+		}
+		logger.Warnf("Withdraw late result, id: %d, txid: %s, %v", withdraw.ID, txid, err)
+		settle(withdraw, txid, err)
+	}()
+}
+
+// 确认超时提现
+// 人工核实已转账后调用，扣除锁定资金
+func ConfirmTimedOut(id uint64, txid string) (*models.Withdraw, error) {
+	model := models.WithdrawModel{}
+	withdraw, err := model.GetWithdraw(id)
+	if err != nil {
+		return nil, err
+	}
+	if withdraw.State != models.WithdrawTimedOut {
+		return nil, models.ErrWithdrawNotTimedOut
+	}
+
+	withdraw, version, err := model.Confirm(id, txid)
+	if err != nil {
+		return nil, err
+	}
+	future.Manager.Remove(strconv.FormatUint(id, 10))
+	logger.Warnf("Withdraw confirmed manually, id: %d, user: %d, asset: %s, amount: %s, txid: %s",
+		withdraw.ID, withdraw.UserID, withdraw.Symbol, withdraw.Amount.String(), txid)
+
+	// 推送提现通知
+	pusher.Post(withdraw.UserID, utils.MakeHistoryMessage(withdraw.UserID, version), true, nil)
+	return withdraw, nil
+}
+
+// 取消超时提现
+// 人工核实未转账后调用，解锁资金
+func FailTimedOut(id uint64, reason string) (*models.Withdraw, error) {
+	model := models.WithdrawModel{}
+	withdraw, err := model.GetWithdraw(id)
+	if err != nil {
+		return nil, err
+	}
+	if withdraw.State != models.WithdrawTimedOut {
+		return nil, models.ErrWithdrawNotTimedOut
+	}
+
+	withdraw, version, err := model.Fail(id, reason)
+	if err != nil {
+		return nil, err
+	}
+	future.Manager.Remove(strconv.FormatUint(id, 10))
+	logger.Warnf("Withdraw failed manually, id: %d, user: %d, asset: %s, amount: %s, reason: %s",
+		withdraw.ID, withdraw.UserID, withdraw.Symbol, withdraw.Amount.String(), reason)
+
+	// 推送提现通知
+	pusher.Post(withdraw.UserID, utils.MakeHistoryMessage(withdraw.UserID, version), true, nil)
+	return withdraw, nil
+}
+
+// 提现失败
+func fail(withdraw *models.Withdraw, reason string) {
+	model := models.WithdrawModel{}
+	_, version, err := model.Fail(withdraw.ID, reason)
+	if err != nil {
+		logger.Errorf("Failed to unlock account when withdraw failure, id: %d, %v", withdraw.ID, err)
+		return
+	}
+
+	// 推送提现通知
+	pusher.Post(withdraw.UserID, utils.MakeHistoryMessage(withdraw.UserID, version), true, nil)
+}
@@ -2,13 +2,16 @@ package luaglue
 
 import (
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/yuin/gopher-lua"
 )
 
 // Lua胶水
+// LState不是线程安全的，所有调用都需要加锁
 type LuaGlue struct {
+	mutex  sync.Mutex
 	closed bool
 	state  *lua.LState
 }
@@ -48,12 +51,17 @@ func (glue *LuaGlue) eventLoop() {
 
 // 释放资源
 func (glue *LuaGlue) Close() {
+	glue.mutex.Lock()
+	defer glue.mutex.Unlock()
 	glue.state.Close()
 	glue.closed = true
 }
 
 // 时钟事件
 func (glue *LuaGlue) OnTick(delaytime float64) {
+	glue.mutex.Lock()
+	defer glue.mutex.Unlock()
+
 	fn := glue.state.GetGlobal("on_tick")
 	if fn == nil {
 		return
@@ -67,7 +75,11 @@ func (glue *LuaGlue) OnTick(delaytime float64) {
 }
 
 // 地址是否有效
-func (glue *LuaGlue) ValidAddress(address string) bool {
+// 按资产符号校验，不同资产的地址格式可能不同
+func (glue *LuaGlue) ValidAddress(address, symbol string) bool {
+	glue.mutex.Lock()
+	defer glue.mutex.Unlock()
+
 	fn := glue.state.GetGlobal("valid_address")
 	if fn == nil {
 		return false
@@ -77,7 +89,7 @@ func (glue *LuaGlue) ValidAddress(address string) bool {
 		Fn:      fn,
 		NRet:    1,
 		Protect: true,
-	}, lua.LString(address))
+	}, lua.LString(address), lua.LString(symbol))
 
 	ret := glue.state.Get(-1)
 	defer glue.state.Pop(1)
@@ -90,7 +102,10 @@ func (glue *LuaGlue) ValidAddress(address string) bool {
 }
 
 // 获取充值地址
-func (glue *LuaGlue) DepositAddress(userID int64) (string, string) {
+func (glue *LuaGlue) DepositAddress(userID int64, symbol string) (string, string) {
+	glue.mutex.Lock()
+	defer glue.mutex.Unlock()
+
 	fn := glue.state.GetGlobal("deposit_address")
 	if fn == nil {
 		return "", ""
@@ -100,7 +115,7 @@ func (glue *LuaGlue) DepositAddress(userID int64) (string, string) {
 		Fn:      fn,
 		NRet:    2,
 		Protect: true,
-	}, lua.LString(strconv.FormatInt(userID, 10)))
+	}, lua.LString(strconv.FormatInt(userID, 10)), lua.LString(symbol))
 
 	address := ""
 	addrRet := glue.state.Get(-2)
@@ -118,7 +133,11 @@ func (glue *LuaGlue) DepositAddress(userID int64) (string, string) {
 }
 
 // 接收提现请求
-func (glue *LuaGlue) OnWithdraw(to, symbol, amount string, id string) {
+// 备注信息和提现ID依次追加在参数末尾，兼容旧版本脚本
+func (glue *LuaGlue) OnWithdraw(withdrawID, to, memo, symbol, amount string, id string) {
+	glue.mutex.Lock()
+	defer glue.mutex.Unlock()
+
 	fn := glue.state.GetGlobal("on_withdraw")
 	if fn == nil {
 		return
@@ -129,11 +148,14 @@ func (glue *LuaGlue) OnWithdraw(to, symbol, amount string, id string) {
 		Fn:      fn,
 		NRet:    0,
 		Protect: true,
-	}, lua.LString(to), lua.LString(symbol), lua.LString(amount), future)
+	}, lua.LString(to), lua.LString(symbol), lua.LString(amount), future, lua.LString(memo), lua.LString(withdrawID))
 }
 
 // 交易是否有效
 func (glue *LuaGlue) ValidTransaction(txid, from, to, symbol, amount, memo string) bool {
+	glue.mutex.Lock()
+	defer glue.mutex.Unlock()
+
 	fn := glue.state.GetGlobal("valid_transaction")
 	if fn == nil {
 		return false
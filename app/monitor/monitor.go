@@ -2,15 +2,14 @@ package monitor
 
 import (
 	"container/heap"
-	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zhangpanyi/basebot/logger"
 	"github.com/zhangpanyi/basebot/telegram/methods"
 	"github.com/zhangpanyi/basebot/telegram/updater"
 	"luckybot/app/config"
-	"luckybot/app/fmath"
 	"luckybot/app/logic/handlers/utils"
 	"luckybot/app/logic/pusher"
 	"luckybot/app/storage"
@@ -47,6 +46,7 @@ func StartChecking(bot *methods.BotExt, pool *updater.Pool) {
 			pool:   pool,
 			expire: serverCfg.Expire,
 		}
+		config.Subscribe(monitor.handleConfigChanged)
 		go monitor.loop()
 	})
 }
@@ -84,6 +84,14 @@ func (t *Monitor) loop() {
 	}
 }
 
+// 处理配置变更
+func (t *Monitor) handleConfigChanged(old, new config.Serve) {
+	if old.Expire != new.Expire {
+		atomic.StoreUint32(&t.expire, new.Expire)
+		logger.Infof("Lucky money expire changed, %d -> %d", old.Expire, new.Expire)
+	}
+}
+
 // 处理过期红包
 func (t *Monitor) handleLuckyMoneyExpire() {
 	var id uint64
@@ -94,7 +102,7 @@ func (t *Monitor) handleLuckyMoneyExpire() {
 		t.lock.RUnlock()
 
 		// 判断是否过期
-		if now-data.Timestamp < int64(t.expire) {
+		if now-data.Timestamp < int64(atomic.LoadUint32(&t.expire)) {
 			return
 		}
 
@@ -128,53 +136,21 @@ func (t *Monitor) asyncHandleLuckyMoneyExpire(id uint64) {
 	if model.IsExpired(id) {
 		return
 	}
-	err := model.SetExpired(id)
+	version, err := model.SetExpired(id)
 	if err != nil {
 		logger.Infof("Failed to set expired of lucky money, %v", err)
 		return
 	}
 
-	// 获取红包信息
-	luckyMoney, received, err := model.GetLuckyMoney(id)
-	if err != nil {
-		logger.Warnf("Failed to set expired of lucky money, not found lucky money, %d, %v", id, err)
-		return
-	}
-
 	// 是否领完了
-	if received == luckyMoney.Number {
+	if version == nil {
 		return
 	}
-
-	// 计算红包余额
-	balance := fmath.Sub(luckyMoney.Amount, luckyMoney.Received)
-	if !luckyMoney.Lucky {
-		amount := fmath.Mul(luckyMoney.Amount, big.NewFloat(float64(luckyMoney.Number)))
-		balance.Sub(amount, luckyMoney.Received)
-	}
-
-	// 返还红包余额
-	accountModel := models.AccountModel{}
-	account, err := accountModel.UnlockAccount(luckyMoney.SenderID, luckyMoney.Asset, balance)
-	if err != nil {
-		logger.Errorf("Failed to return lucky money asset of expired, %v", err)
-		return
-	}
-	logger.Errorf("Return lucky money asset of expired, user=%d, asset=%s, amount=%s",
-		luckyMoney.SenderID, luckyMoney.Asset, balance.String())
-
-	// 插入账户记录
-	zero := big.NewFloat(0)
-	versionModel := models.AccountVersionModel{}
-	version, err := versionModel.InsertVersion(luckyMoney.SenderID, &models.Version{
-		Symbol:          luckyMoney.Asset,
-		Locked:          zero.Sub(zero, balance),
-		Amount:          account.Amount,
-		Reason:          models.ReasonGiveBack,
-		RefLuckyMoneyID: &luckyMoney.ID,
-	})
+	logger.Errorf("Return lucky money asset of expired, id=%d, asset=%s, amount=%s",
+		id, version.Symbol, version.Locked.Abs().String())
 
 	// 推送退还通知
+	luckyMoney, _, err := model.GetLuckyMoney(id)
 	if err == nil {
 		pusher.Post(luckyMoney.SenderID, utils.MakeHistoryMessage(luckyMoney.SenderID, version), true, nil)
 	}
@@ -0,0 +1,89 @@
+package poll
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 全局健康状态
+var globalHealth *health
+
+// 健康状态
+type Health struct {
+	Healthy      bool   `json:"healthy"`       // 是否健康
+	Failures     int    `json:"failures"`      // 连续失败次数
+	FailingSince int64  `json:"failing_since"` // 开始失败时间
+	LastSuccess  int64  `json:"last_success"`  // 最近成功时间
+	LastError    string `json:"last_error"`    // 最近错误信息
+}
+
+// 获取健康状态
+// 未使用轮询时总是健康
+func GetHealth() Health {
+	if globalHealth == nil {
+		return Health{Healthy: true}
+	}
+	return globalHealth.get()
+}
+
+// 健康检查接口
+// 健康返回200，否则返回503，供负载均衡或监控探测
+func HandleHealth(w http.ResponseWriter, r *http.Request) {
+	state := GetHealth()
+	jsb, err := json.Marshal(&state)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if state.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(jsb)
+}
+
+// 健康检查
+type health struct {
+	mutex          sync.Mutex
+	unhealthyAfter time.Duration
+	state          Health
+}
+
+func newHealth(unhealthyAfter time.Duration) *health {
+	return &health{unhealthyAfter: unhealthyAfter}
+}
+
+// 记录成功
+func (h *health) success() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.state.Failures = 0
+	h.state.FailingSince = 0
+	h.state.LastError = ""
+	h.state.LastSuccess = time.Now().UTC().Unix()
+}
+
+// 记录失败
+func (h *health) failure(err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.state.Failures == 0 {
+		h.state.FailingSince = time.Now().UTC().Unix()
+	}
+	h.state.Failures++
+	h.state.LastError = err.Error()
+}
+
+// 获取状态
+func (h *health) get() Health {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	state := h.state
+	state.Healthy = state.Failures == 0 ||
+		time.Now().UTC().Unix()-state.FailingSince < int64(h.unhealthyAfter/time.Second)
+	return state
+}
@@ -1,25 +1,46 @@
 package poll
 
 import (
+	"math/rand"
+	"time"
+
 	"github.com/zhangpanyi/basebot/logger"
 	"github.com/zhangpanyi/basebot/telegram/methods"
-	"github.com/zhangpanyi/basebot/telegram/updater"
+	"github.com/zhangpanyi/basebot/telegram/types"
+	"luckybot/app/dispatcher"
+	"luckybot/app/storage/models"
 )
 
+// 最小重试间隔
+const minBackoff = time.Second
+
+// 最大重试间隔
+const maxBackoff = time.Minute
+
+// 更新处理函数
+// 处理完成后调用done，done为空时无需通知
+type Handler func(bot *methods.BotExt, update *types.Update, done func())
+
 // 轮询器
 type Poller struct {
 	apiaccess string
+	health    *health
 }
 
 // 创建轮询器
-func NewPoller(apiaccess string) *Poller {
+// 拉取更新连续失败超过unhealthyAfter后标记为不健康
+func NewPoller(apiaccess string, unhealthyAfter time.Duration) *Poller {
 	poller := new(Poller)
 	poller.apiaccess = apiaccess
+	poller.health = newHealth(unhealthyAfter)
+	globalHealth = poller.health
 	return poller
 }
 
 // 开始轮询
-func (poller *Poller) StartPoll(token string, handler updater.Handler) (*methods.BotExt, error) {
+// 从数据库恢复更新偏移，更新交给分发器后继续拉取，不等待处理完成，
+// 数据库中保存连续处理完成的更新偏移
+func (poller *Poller) StartPoll(token string, handler Handler) (*methods.BotExt, error) {
 	bot, err := methods.GetMe(poller.apiaccess, token)
 	if err != nil {
 		return nil, err
@@ -28,23 +49,55 @@ func (poller *Poller) StartPoll(token string, handler updater.Handler) (*methods
 	if err != nil {
 		return nil, err
 	}
-	go poller.startPoll(bot, handler)
-	return bot, nil
 
+	model := models.PollerModel{}
+	offset, err := model.GetOffset(bot.ID)
+	if err != nil {
+		return nil, err
+	}
+	go poller.startPoll(bot, offset, handler)
+	return bot, nil
 }
 
-func (poller *Poller) startPoll(bot *methods.BotExt, handler updater.Handler) {
-	var offset uint32
+func (poller *Poller) startPoll(bot *methods.BotExt, offset uint32, handler Handler) {
+	saved := offset
+	backoff := minBackoff
+	model := models.PollerModel{}
+	tracker := dispatcher.NewOffsetTracker(offset)
 	for {
 		updates, err := bot.GetUpdates(5, offset)
 		if err != nil {
-			logger.Infof("Failed to get updates, %v", err)
+			poller.health.failure(err)
+			delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+			logger.Infof("Failed to get updates, retry after %v, %v", delay, err)
+			time.Sleep(delay)
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
 			continue
 		}
+		poller.health.success()
+		backoff = minBackoff
 
+		// 交给分发器处理
 		for i := 0; i < len(updates); i++ {
-			go handler(bot, updates[i])
-			offset = uint32(updates[i].UpdateID + 1)
+			updateID := uint32(updates[i].UpdateID)
+			tracker.Track(updateID)
+			handler(bot, updates[i], func() {
+				tracker.Done(updateID)
+			})
+		}
+		if len(updates) > 0 {
+			offset = uint32(updates[len(updates)-1].UpdateID + 1)
+		}
+
+		// 保存更新偏移
+		if committed := tracker.Offset(); committed != saved {
+			if err = model.SetOffset(bot.ID, committed); err != nil {
+				logger.Warnf("Failed to save poller offset, offset: %d, %v", committed, err)
+				continue
+			}
+			saved = committed
 		}
 	}
 }
@@ -0,0 +1,122 @@
+package poll
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/zhangpanyi/basebot/logger"
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"github.com/zhangpanyi/basebot/telegram/types"
+	"luckybot/app/config"
+)
+
+// 最大请求长度
+const maxWebhookBodySize = 1 << 20
+
+// 接收的更新类型
+var allowedUpdates = []string{"message", "callback_query", "inline_query"}
+
+// 开始Webhook
+// 在路由上挂载接收器并向电报注册Webhook，请求需匹配秘密路径和秘密令牌，
+// 调用前必须已经监听HTTP端口
+func (poller *Poller) StartWebhook(token string, cfg config.Webhook,
+	router *mux.Router, handler Handler) (*methods.BotExt, error) {
+
+	bot, err := methods.GetMe(poller.apiaccess, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// 挂载接收器
+	path := "/webhook/" + cfg.SecretPath
+	router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		poller.handleWebhook(bot, cfg.SecretToken, handler, w, r)
+	}).Methods(http.MethodPost)
+
+	// 注册Webhook
+	url := strings.TrimRight(cfg.URL, "/") + path
+	if err = poller.setWebhook(bot, url, &cfg); err != nil {
+		return nil, err
+	}
+	return bot, nil
+}
+
+// 删除Webhook
+// HTTP服务停止后调用，避免电报继续向无法访问的地址推送
+func (poller *Poller) DeleteWebhook(token string) error {
+	return methods.DelWebhook(poller.apiaccess, token)
+}
+
+// 注册Webhook
+func (poller *Poller) setWebhook(bot *methods.BotExt, url string, cfg *config.Webhook) error {
+	updates, err := json.Marshal(allowedUpdates)
+	if err != nil {
+		return err
+	}
+
+	formdata := []methods.Field{
+		methods.Field{Name: "url", Text: url},
+		methods.Field{Name: "allowed_updates", Text: string(updates)},
+	}
+	if cfg.MaxConnections > 0 {
+		formdata = append(formdata, methods.Field{
+			Name: "max_connections",
+			Text: strconv.Itoa(int(cfg.MaxConnections)),
+		})
+	}
+	if len(cfg.SecretToken) > 0 {
+		formdata = append(formdata, methods.Field{Name: "secret_token", Text: cfg.SecretToken})
+	}
+
+	// 上传自签名证书
+	if len(cfg.Certificate) > 0 {
+		certificate, err := ioutil.ReadFile(cfg.Certificate)
+		if err != nil {
+			return err
+		}
+		formdata = append(formdata, methods.Field{
+			Name:     "certificate",
+			File:     certificate,
+			FileName: "public.pem",
+		})
+	}
+
+	_, err = bot.Upload("setWebhook", formdata)
+	return err
+}
+
+// 处理Webhook请求
+func (poller *Poller) handleWebhook(bot *methods.BotExt, secretToken string, handler Handler,
+	w http.ResponseWriter, r *http.Request) {
+
+	// 验证秘密令牌
+	if len(secretToken) > 0 {
+		token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secretToken)) != 1 {
+			logger.Warnf("Webhook secret token mismatch, %s", r.RemoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// 解析更新信息
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	update := new(types.Update)
+	if err = update.FromJSON(data); err != nil {
+		logger.Warnf("Failed to decode webhook update, %v", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	handler(bot, update, nil)
+	w.WriteHeader(http.StatusOK)
+}
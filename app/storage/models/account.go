@@ -3,7 +3,6 @@ package models
 import (
 	"encoding/json"
 	"errors"
-	"math/big"
 	"strconv"
 
 	"github.com/boltdb/bolt"
@@ -13,20 +12,10 @@ import (
 
 // 账户数据
 type Account struct {
-	Symbol  string     `json:"symbol"`  // 货币符号
-	Amount  *big.Float `json:"amount"`  // 资产金额
-	Locked  *big.Float `json:"locked"`  // 锁定金额
-	Disable bool       `json:"disable"` // 禁用账户
-}
-
-// 标准化
-func (account *Account) Normalization() {
-	if account.Amount != nil {
-		account.Amount.SetPrec(fmath.Prec())
-	}
-	if account.Locked != nil {
-		account.Locked.SetPrec(fmath.Prec())
-	}
+	Symbol  string        `json:"symbol"`  // 货币符号
+	Amount  fmath.Decimal `json:"amount"`  // 资产金额
+	Locked  fmath.Decimal `json:"locked"`  // 锁定金额
+	Disable bool          `json:"disable"` // 禁用账户
 }
 
 var (
@@ -67,7 +56,6 @@ func (model *AccountModel) GetAccounts(userID int64) ([]*Account, error) {
 			if err = json.Unmarshal(v, &account); err != nil {
 				return err
 			}
-			account.Normalization()
 			accounts = append(accounts, &account)
 			return nil
 		})
@@ -102,7 +90,6 @@ func (model *AccountModel) GetAccount(userID int64, symbol string) (*Account, er
 		if err = json.Unmarshal(jsb, &account); err != nil {
 			return err
 		}
-		account.Normalization()
 		return nil
 	})
 
@@ -112,245 +99,40 @@ func (model *AccountModel) GetAccount(userID int64, symbol string) (*Account, er
 	return &account, nil
 }
 
-// 账户存款操作
-func (model *AccountModel) Deposit(userID int64, symbol string, amount *big.Float) (*Account, error) {
-	var account Account
+// 在事务中获取账户
+func getAccount(tx *bolt.Tx, userID int64, symbol string) (*Account, error) {
 	key := strconv.FormatInt(userID, 10)
-	err := storage.DB.Update(func(tx *bolt.Tx) error {
-		bucket, err := storage.EnsureBucketExists(tx, "accounts", key)
-		if err != nil {
-			return err
-		}
-
-		jsb := bucket.Get([]byte(symbol))
-		if jsb == nil {
-			account.Symbol = symbol
-			account.Amount = amount
-			account.Locked = big.NewFloat(0)
-		} else {
-			if err = json.Unmarshal(jsb, &account); err != nil {
-				return err
-			}
-			account.Normalization()
-			account.Amount.Add(account.Amount, amount)
-		}
-
-		jsb, err = json.Marshal(&account)
-		if err != nil {
-			return err
-		}
-
-		if err = bucket.Put([]byte(symbol), jsb); err != nil {
-			return err
-		}
-		return nil
-	})
-
+	bucket, err := storage.GetBucketIfExists(tx, "accounts", key)
 	if err != nil {
-		return nil, err
-	}
-	return &account, nil
-}
-
-// 账户取款操作
-func (model *AccountModel) Withdraw(userID int64, symbol string, amount *big.Float) (*Account, error) {
-	var account Account
-	key := strconv.FormatInt(userID, 10)
-	err := storage.DB.Update(func(tx *bolt.Tx) error {
-		bucket, err := storage.EnsureBucketExists(tx, "accounts", key)
-		if err != nil {
-			return err
-		}
-
-		jsb := bucket.Get([]byte(symbol))
-		if jsb == nil {
-			return ErrNoSuchTypeAccount
+		if err != storage.ErrNoBucket {
+			return nil, err
 		}
+		return nil, ErrNoSuchTypeAccount
+	}
 
-		if err = json.Unmarshal(jsb, &account); err != nil {
-			return err
-		}
-		account.Normalization()
-
-		if account.Locked.Cmp(amount) == 1 {
-			return ErrInsufficientAmount
-		}
-		account.Locked.Sub(account.Locked, amount)
-
-		jsb, err = json.Marshal(&account)
-		if err != nil {
-			return err
-		}
-
-		if err = bucket.Put([]byte(symbol), jsb); err != nil {
-			return err
-		}
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
+	jsb := bucket.Get([]byte(symbol))
+	if jsb == nil {
+		return nil, ErrNoSuchTypeAccount
 	}
-	return &account, nil
-}
 
-// 锁定账户资金
-func (model *AccountModel) LockAccount(userID int64, symbol string, amount *big.Float) (*Account, error) {
 	var account Account
-	key := strconv.FormatInt(userID, 10)
-	err := storage.DB.Update(func(tx *bolt.Tx) error {
-		bucket, err := storage.EnsureBucketExists(tx, "accounts", key)
-		if err != nil {
-			return err
-		}
-
-		jsb := bucket.Get([]byte(symbol))
-		if jsb == nil {
-			return ErrNoSuchTypeAccount
-		}
-
-		if err = json.Unmarshal(jsb, &account); err != nil {
-			return err
-		}
-		account.Normalization()
-
-		if amount.Cmp(account.Amount) == 1 {
-			return ErrInsufficientAmount
-		}
-		account.Locked.Add(account.Locked, amount)
-		account.Amount.Sub(account.Amount, amount)
-
-		jsb, err = json.Marshal(&account)
-		if err != nil {
-			return err
-		}
-
-		if err = bucket.Put([]byte(symbol), jsb); err != nil {
-			return err
-		}
-		return nil
-	})
-
-	if err != nil {
+	if err = json.Unmarshal(jsb, &account); err != nil {
 		return nil, err
 	}
 	return &account, nil
 }
 
-// 解锁账户资金
-func (model *AccountModel) UnlockAccount(userID int64, symbol string, amount *big.Float) (*Account, error) {
-	var account Account
+// 在事务中保存账户
+func putAccount(tx *bolt.Tx, userID int64, account *Account) error {
 	key := strconv.FormatInt(userID, 10)
-	err := storage.DB.Update(func(tx *bolt.Tx) error {
-		bucket, err := storage.EnsureBucketExists(tx, "accounts", key)
-		if err != nil {
-			return err
-		}
-
-		jsb := bucket.Get([]byte(symbol))
-		if jsb == nil {
-			return ErrNoSuchTypeAccount
-		}
-
-		if err = json.Unmarshal(jsb, &account); err != nil {
-			return err
-		}
-		account.Normalization()
-
-		if amount.Cmp(account.Locked) == 1 {
-			return ErrInsufficientAmount
-		}
-		account.Locked.Sub(account.Locked, amount)
-		account.Amount.Add(account.Amount, amount)
-
-		jsb, err = json.Marshal(&account)
-		if err != nil {
-			return err
-		}
-
-		if err = bucket.Put([]byte(symbol), jsb); err != nil {
-			return err
-		}
-		return nil
-	})
-
+	bucket, err := storage.EnsureBucketExists(tx, "accounts", key)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &account, nil
-}
-
-// 从锁定账户转账
-func (model *AccountModel) TransferFromLockAccount(from, to int64, symbol string,
-	amount *big.Float) (*Account, *Account, error) {
-
-	var toAccount Account
-	var fromAccount Account
-	toKey := strconv.FormatInt(to, 10)
-	fromKey := strconv.FormatInt(from, 10)
-	err := storage.DB.Update(func(tx *bolt.Tx) error {
-		// 获取桶
-		toBucket, err := storage.EnsureBucketExists(tx, "accounts", toKey)
-		if err != nil {
-			return err
-		}
-
-		fromBucket, err := storage.EnsureBucketExists(tx, "accounts", fromKey)
-		if err != nil {
-			return err
-		}
-
-		// 获取账户信息
-		jsb := fromBucket.Get([]byte(symbol))
-		if jsb == nil {
-			return ErrNoSuchTypeAccount
-		}
-		if err = json.Unmarshal(jsb, &fromAccount); err != nil {
-			return err
-		}
-		fromAccount.Normalization()
-
-		if amount.Cmp(fromAccount.Locked) == 1 {
-			return ErrInsufficientAmount
-		}
-		fromAccount.Locked.Sub(fromAccount.Locked, amount)
-
-		jsb, err = json.Marshal(&fromAccount)
-		if err != nil {
-			return err
-		}
-
-		if err = fromBucket.Put([]byte(symbol), jsb); err != nil {
-			return err
-		}
-
-		// 转移锁定资产
-		jsb = toBucket.Get([]byte(symbol))
-		if jsb == nil {
-			toAccount.Symbol = symbol
-			toAccount.Amount = amount
-			toAccount.Locked = big.NewFloat(0)
-		} else {
-			if err = json.Unmarshal(jsb, &toAccount); err != nil {
-				return err
-			}
-			toAccount.Normalization()
-			toAccount.Amount.Add(toAccount.Amount, amount)
-		}
-
-		jsb, err = json.Marshal(&toAccount)
-		if err != nil {
-			return err
-		}
-
-		if err = toBucket.Put([]byte(symbol), jsb); err != nil {
-			return err
-		}
-		return nil
-	})
 
+	jsb, err := json.Marshal(account)
 	if err != nil {
-		return nil, nil, err
+		return err
 	}
-	return &fromAccount, &toAccount, nil
+	return bucket.Put([]byte(account.Symbol), jsb)
 }
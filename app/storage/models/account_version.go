@@ -2,7 +2,6 @@ package models
 
 import (
 	"encoding/json"
-	"math/big"
 	"strconv"
 	"time"
 
@@ -15,34 +14,38 @@ import (
 type Reason int
 
 const (
-	_                     Reason = iota
-	ReasonGive                   // 发红包
-	ReasonSystem                 // 系统发放
-	ReasonReceive                // 领取红包
-	ReasonGiveBack               // 退还红包
-	ReasonDeposit                // 充值
-	ReasonWithdraw               // 提现
-	ReasonWithdrawSuccess        // 提现成功
-	ReasonWithdrawFailure        // 提现失败
+	_                      Reason = iota
+	ReasonGive                    // 发红包
+	ReasonSystem                  // 系统发放
+	ReasonReceive                 // 领取红包
+	ReasonGiveBack                // 退还红包
+	ReasonDeposit                 // 充值
+	ReasonWithdraw                // 提现
+	ReasonWithdrawSuccess         // 提现成功
+	ReasonWithdrawFailure         // 提现失败
+	ReasonClaimed                 // 红包被领取
+	ReasonWithdrawRejected        // 提现被拒绝
 )
 
 // 版本信息
 type Version struct {
-	ID              uint64     `json:"id"`                           // 版本ID
-	Symbol          string     `json:"symbol"`                       // 代币符号
-	Balance         *big.Float `json:"balance,omitempty"`            // 余额变化
-	Locked          *big.Float `json:"locked,omitempty"`             // 锁定变化
-	Fee             *big.Float `json:"fee,omitempty"`                // 手续费
-	Amount          *big.Float `json:"amount"`                       // 剩余金额
-	Timestamp       int64      `json:"timestamp"`                    // 时间戳
-	Reason          Reason     `json:"reason"`                       // 触发原因
-	RefLuckyMoneyID *uint64    `json:"ref_lucky_money_id,omitempty"` // 关联红包ID
-	RefBlockHeight  *uint64    `json:"ref_block_height,omitempty"`   // 关联区块高度
-	RefTxID         *string    `json:"ref_tx_id,omitempty"`          // 关联交易ID
-	RefUserID       *int64     `json:"ref_user_id,omitempty"`        // 关联用户ID
-	RefUserName     *string    `json:"ref_user_name,omitempty"`      // 关联用户名
-	RefAddress      *string    `json:"ref_address,omitempty"`        // 关联地址
-	RefMemo         *string    `json:"ref_memo,omitempty"`           // 关联备注信息
+	ID              uint64         `json:"id"`                           // 版本ID
+	Symbol          string         `json:"symbol"`                       // 代币符号
+	Balance         *fmath.Decimal `json:"balance,omitempty"`            // 余额变化
+	Locked          *fmath.Decimal `json:"locked,omitempty"`             // 锁定变化
+	Fee             *fmath.Decimal `json:"fee,omitempty"`                // 手续费
+	Amount          fmath.Decimal  `json:"amount"`                       // 剩余金额
+	Timestamp       int64          `json:"timestamp"`                    // 时间戳
+	Reason          Reason         `json:"reason"`                       // 触发原因
+	RefLuckyMoneyID *uint64        `json:"ref_lucky_money_id,omitempty"` // 关联红包ID
+	RefBlockHeight  *uint64        `json:"ref_block_height,omitempty"`   // 关联区块高度
+	RefTxID         *string        `json:"ref_tx_id,omitempty"`          // 关联交易ID
+	RefUserID       *int64         `json:"ref_user_id,omitempty"`        // 关联用户ID
+	RefUserName     *string        `json:"ref_user_name,omitempty"`      // 关联用户名
+	RefAddress      *string        `json:"ref_address,omitempty"`        // 关联地址
+	RefMemo         *string        `json:"ref_memo,omitempty"`           // 关联备注信息
+	RefWithdrawID   *uint64        `json:"ref_withdraw_id,omitempty"`    // 关联提现ID
+	JournalID       uint64         `json:"journal_id,omitempty"`         // 记账凭证ID
 }
 
 // ********************** 结构图 **********************
@@ -58,34 +61,26 @@ type Version struct {
 type AccountVersionModel struct {
 }
 
-// 插入版本
-func (model *AccountVersionModel) InsertVersion(userID int64, version *Version) (*Version, error) {
+// 在事务中插入版本
+func insertVersion(tx *bolt.Tx, userID int64, version *Version) error {
 	key := strconv.FormatInt(userID, 10)
-	version.Timestamp = time.Now().UTC().Unix()
-	err := storage.DB.Update(func(tx *bolt.Tx) error {
-		bucket, err := storage.EnsureBucketExists(tx, "account_versions", key)
-		if err != nil {
-			return err
-		}
-
-		seq, err := bucket.NextSequence()
-		if err != nil {
-			return err
-		}
-
-		version.ID = seq
-		jsb, err := json.Marshal(version)
-		if err != nil {
-			return err
-		}
-		return bucket.Put([]byte(strconv.FormatUint(seq, 10)), jsb)
-	})
+	bucket, err := storage.EnsureBucketExists(tx, "account_versions", key)
+	if err != nil {
+		return err
+	}
 
+	seq, err := bucket.NextSequence()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return version, nil
+	version.ID = seq
+	version.Timestamp = time.Now().UTC().Unix()
+	jsb, err := json.Marshal(version)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(strconv.FormatUint(seq, 10)), jsb)
 }
 
 // 获取版本
@@ -148,19 +143,90 @@ func (model *AccountVersionModel) GetVersions(userID int64, offset, limit uint,
 		if err = json.Unmarshal(jsb, &version); err != nil {
 			return nil, 0, err
 		}
-		if version.Balance != nil {
-			version.Balance.SetPrec(fmath.Prec())
+		versions = append(versions, &version)
+	}
+	return versions, sum, nil
+}
+
+// 获取指定时间以来的版本
+// 按ID倒序遍历，返回指定代币的版本
+func (model *AccountVersionModel) GetVersionsSince(userID int64, symbol string, since int64) ([]*Version, error) {
+	versions := make([]*Version, 0)
+	key := strconv.FormatInt(userID, 10)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "account_versions", key)
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
 		}
-		if version.Locked != nil {
-			version.Locked.SetPrec(fmath.Prec())
+
+		for i := bucket.Sequence(); i >= uint64(1); i-- {
+			jsb := bucket.Get([]byte(strconv.FormatUint(i, 10)))
+			if jsb == nil {
+				continue
+			}
+
+			var version Version
+			if err = json.Unmarshal(jsb, &version); err != nil {
+				return err
+			}
+			if version.Timestamp < since {
+				break
+			}
+			if version.Symbol == symbol {
+				versions = append(versions, &version)
+			}
 		}
-		if version.Fee != nil {
-			version.Fee.SetPrec(fmath.Prec())
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// 是否存在指定原因的版本
+// 从最新版本向前查找，直到时间早于since
+func (model *AccountVersionModel) HasReasonSince(userID int64, since int64, reasons ...Reason) (bool, error) {
+	found := false
+	key := strconv.FormatInt(userID, 10)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "account_versions", key)
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
 		}
-		if version.Amount != nil {
-			version.Amount.SetPrec(fmath.Prec())
+
+		for i := bucket.Sequence(); i >= uint64(1); i-- {
+			jsb := bucket.Get([]byte(strconv.FormatUint(i, 10)))
+			if jsb == nil {
+				continue
+			}
+
+			var version Version
+			if err = json.Unmarshal(jsb, &version); err != nil {
+				return err
+			}
+			if version.Timestamp < since {
+				break
+			}
+			for _, reason := range reasons {
+				if version.Reason == reason {
+					found = true
+					return nil
+				}
+			}
 		}
-		versions = append(versions, &version)
+		return nil
+	})
+
+	if err != nil {
+		return false, err
 	}
-	return versions, sum, nil
+	return found, nil
 }
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"luckybot/app/storage"
+)
+
+// 地址条目
+type AddressEntry struct {
+	ID        uint64 `json:"id"`             // 条目ID
+	Symbol    string `json:"symbol"`         // 代币符号
+	Label     string `json:"label"`          // 标签
+	Address   string `json:"address"`        // 地址
+	Memo      string `json:"memo,omitempty"` // 备注信息
+	CreatedAt int64  `json:"created_at"`     // 创建时间
+}
+
+var (
+	// 地址不存在
+	ErrAddressNotFound = errors.New("address not found")
+	// 地址已存在
+	ErrAddressExists = errors.New("address already exists")
+	// 地址簿已满
+	ErrAddressBookFull = errors.New("address book full")
+)
+
+// ********************** 结构图 **********************
+// {
+//	"address_book": {
+// 		<user_id>: {
+// 			<id>: AddressEntry	// 地址条目
+// 		}
+//	}
+// }
+// ***************************************************
+
+// 地址簿模型
+type AddressBookModel struct {
+}
+
+// 添加地址
+func (model *AddressBookModel) AddAddress(userID int64, entry *AddressEntry, limit int) (*AddressEntry, error) {
+	key := strconv.FormatInt(userID, 10)
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "address_book", key)
+		if err != nil {
+			return err
+		}
+
+		// 检查地址数量
+		count := 0
+		err = bucket.ForEach(func(k, v []byte) error {
+			var saved AddressEntry
+			if err := json.Unmarshal(v, &saved); err != nil {
+				return err
+			}
+			if saved.Symbol == entry.Symbol && saved.Address == entry.Address && saved.Memo == entry.Memo {
+				return ErrAddressExists
+			}
+			count++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if limit > 0 && count >= limit {
+			return ErrAddressBookFull
+		}
+
+		// 保存地址条目
+		entry.ID, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.CreatedAt = time.Now().UTC().Unix()
+		jsb, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(strconv.FormatUint(entry.ID, 10)), jsb)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// 获取地址
+func (model *AddressBookModel) GetAddress(userID int64, id uint64) (*AddressEntry, error) {
+	var entry AddressEntry
+	key := strconv.FormatInt(userID, 10)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "address_book", key)
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return ErrAddressNotFound
+		}
+
+		jsb := bucket.Get([]byte(strconv.FormatUint(id, 10)))
+		if jsb == nil {
+			return ErrAddressNotFound
+		}
+		return json.Unmarshal(jsb, &entry)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// 获取地址列表
+// 代币符号为空时返回所有地址
+func (model *AddressBookModel) GetAddresses(userID int64, symbol string) ([]*AddressEntry, error) {
+	entries := make([]*AddressEntry, 0)
+	key := strconv.FormatInt(userID, 10)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "address_book", key)
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		for i := uint64(1); i <= bucket.Sequence(); i++ {
+			jsb := bucket.Get([]byte(strconv.FormatUint(i, 10)))
+			if jsb == nil {
+				continue
+			}
+
+			var entry AddressEntry
+			if err = json.Unmarshal(jsb, &entry); err != nil {
+				return err
+			}
+			if len(symbol) > 0 && entry.Symbol != symbol {
+				continue
+			}
+			entries = append(entries, &entry)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// 删除地址
+func (model *AddressBookModel) RemoveAddress(userID int64, id uint64) error {
+	key := strconv.FormatInt(userID, 10)
+	return storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "address_book", key)
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return ErrAddressNotFound
+		}
+
+		k := []byte(strconv.FormatUint(id, 10))
+		if bucket.Get(k) == nil {
+			return ErrAddressNotFound
+		}
+		return bucket.Delete(k)
+	})
+}
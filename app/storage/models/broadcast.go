@@ -0,0 +1,395 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"luckybot/app/fmath"
+	"luckybot/app/storage"
+)
+
+// 广播状态
+type BroadcastState int
+
+const (
+	_                  BroadcastState = iota
+	BroadcastRunning                  // 发送中
+	BroadcastPaused                   // 已暂停
+	BroadcastCancelled                // 已取消
+	BroadcastFinished                 // 已完成
+)
+
+// 是否结束
+func (state BroadcastState) Finished() bool {
+	return state == BroadcastCancelled || state == BroadcastFinished
+}
+
+// 受众条件
+// 多个条件同时满足，未设置的条件不限制
+type Segment struct {
+	UserIDs    []int64        `json:"user_ids,omitempty"`    // 指定用户列表
+	Symbol     string         `json:"symbol,omitempty"`      // 余额资产符号
+	MinBalance *fmath.Decimal `json:"min_balance,omitempty"` // 余额大于
+	ActiveDays int            `json:"active_days,omitempty"` // 最近N天收发过红包
+	Languages  []string       `json:"languages,omitempty"`   // 语言包代码
+}
+
+// 广播内容
+// 解析模式为空时使用Markdown，上传的文件发送成功后替换为文件ID
+type BroadcastContent struct {
+	Message   string                        `json:"message"`              // 消息内容或媒体标题
+	ParseMode string                        `json:"parse_mode,omitempty"` // 解析模式
+	Media     string                        `json:"media,omitempty"`      // 媒体类型
+	FileID    string                        `json:"file_id,omitempty"`    // 媒体文件ID
+	File      []byte                        `json:"file,omitempty"`       // 上传文件
+	FileName  string                        `json:"file_name,omitempty"`  // 上传文件名
+	Markup    *methods.InlineKeyboardMarkup `json:"markup,omitempty"`     // 内联键盘
+}
+
+// 生成推送消息
+func (content *BroadcastContent) PushMessage(receiver int64) *PushMessage {
+	msg := PushMessage{
+		Receiver:  receiver,
+		Text:      content.Message,
+		ParseMode: content.ParseMode,
+		Media:     content.Media,
+		FileID:    content.FileID,
+		Markup:    content.Markup,
+	}
+	if len(msg.ParseMode) == 0 {
+		msg.Markdown = true
+	}
+	return &msg
+}
+
+// 广播任务
+type Broadcast struct {
+	ID uint64 `json:"id"` // 任务ID
+	BroadcastContent
+	Segment   *Segment       `json:"segment"`    // 受众条件
+	State     BroadcastState `json:"state"`      // 任务状态
+	Total     int            `json:"total"`      // 接收者数量
+	Cursor    int            `json:"cursor"`     // 发送进度
+	Sent      int            `json:"sent"`       // 成功数量
+	Failed    int            `json:"failed"`     // 失败数量
+	Blocked   int            `json:"blocked"`    // 屏蔽数量
+	CreatedAt int64          `json:"created_at"` // 创建时间
+	UpdatedAt int64          `json:"updated_at"` // 更新时间
+}
+
+// 广播进度
+type BroadcastProgress struct {
+	Cursor  int // 发送进度
+	Sent    int // 成功数量
+	Failed  int // 失败数量
+	Blocked int // 屏蔽数量
+}
+
+var (
+	// 广播任务不存在
+	ErrBroadcastNotFound = errors.New("broadcast not found")
+	// 广播任务已结束
+	ErrBroadcastFinished = errors.New("broadcast already finished")
+	// 广播状态错误
+	ErrBroadcastState = errors.New("invalid broadcast state")
+)
+
+// ********************** 结构图 **********************
+// {
+//	"broadcasts": {
+// 		"jobs": {
+// 			<id>: Broadcast	// 广播任务
+// 		},
+// 		"receivers": {
+// 			<id>: {
+// 				<index>: <user_id>	// 接收者列表
+// 			}
+// 		}
+//	}
+// }
+// ***************************************************
+
+// 广播模型
+type BroadcastModel struct {
+}
+
+// 获取广播任务
+func (model *BroadcastModel) getBroadcast(tx *bolt.Tx, id uint64) (*Broadcast, error) {
+	bucket, err := storage.GetBucketIfExists(tx, "broadcasts", "jobs")
+	if err != nil {
+		if err != storage.ErrNoBucket {
+			return nil, err
+		}
+		return nil, ErrBroadcastNotFound
+	}
+
+	jsb := bucket.Get([]byte(strconv.FormatUint(id, 10)))
+	if jsb == nil {
+		return nil, ErrBroadcastNotFound
+	}
+
+	var broadcast Broadcast
+	if err = json.Unmarshal(jsb, &broadcast); err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
+// 保存广播任务
+func (model *BroadcastModel) putBroadcast(tx *bolt.Tx, broadcast *Broadcast) error {
+	bucket, err := storage.EnsureBucketExists(tx, "broadcasts", "jobs")
+	if err != nil {
+		return err
+	}
+
+	broadcast.UpdatedAt = time.Now().UTC().Unix()
+	jsb, err := json.Marshal(broadcast)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(strconv.FormatUint(broadcast.ID, 10)), jsb)
+}
+
+// 创建广播任务
+func (model *BroadcastModel) NewBroadcast(broadcast *Broadcast, receivers []int64) (*Broadcast, error) {
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "broadcasts", "jobs")
+		if err != nil {
+			return err
+		}
+
+		broadcast.ID, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		broadcast.State = BroadcastRunning
+		broadcast.Total = len(receivers)
+		broadcast.CreatedAt = time.Now().UTC().Unix()
+		if err = model.putBroadcast(tx, broadcast); err != nil {
+			return err
+		}
+
+		// 保存接收者列表
+		key := strconv.FormatUint(broadcast.ID, 10)
+		receiversBucket, err := storage.EnsureBucketExists(tx, "broadcasts", "receivers", key)
+		if err != nil {
+			return err
+		}
+		for idx, userID := range receivers {
+			value := []byte(strconv.FormatInt(userID, 10))
+			if err = receiversBucket.Put(orderedKey(uint64(idx)), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return broadcast, nil
+}
+
+// 获取广播任务
+func (model *BroadcastModel) GetBroadcast(id uint64) (*Broadcast, error) {
+	var broadcast *Broadcast
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		broadcast, err = model.getBroadcast(tx, id)
+		return err
+	})
+	return broadcast, err
+}
+
+// 获取广播任务列表
+// 按时间倒序返回
+func (model *BroadcastModel) GetBroadcasts(offset, limit uint) ([]*Broadcast, uint, error) {
+	sum := uint(0)
+	broadcasts := make([]*Broadcast, 0)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "broadcasts", "jobs")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		for i := bucket.Sequence(); i > 0; i-- {
+			jsb := bucket.Get([]byte(strconv.FormatUint(i, 10)))
+			if jsb == nil {
+				continue
+			}
+
+			sum++
+			if sum <= offset || uint(len(broadcasts)) >= limit {
+				continue
+			}
+			var broadcast Broadcast
+			if err = json.Unmarshal(jsb, &broadcast); err != nil {
+				return err
+			}
+			broadcasts = append(broadcasts, &broadcast)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+	return broadcasts, sum, nil
+}
+
+// 获取待发送任务
+// 返回最早创建的发送中任务，没有时返回nil
+func (model *BroadcastModel) GetRunning() (*Broadcast, error) {
+	var broadcast *Broadcast
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "broadcasts", "jobs")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		for i := uint64(1); i <= bucket.Sequence(); i++ {
+			jsb := bucket.Get([]byte(strconv.FormatUint(i, 10)))
+			if jsb == nil {
+				continue
+			}
+
+			var job Broadcast
+			if err = json.Unmarshal(jsb, &job); err != nil {
+				return err
+			}
+			if job.State == BroadcastRunning {
+				broadcast = &job
+				return nil
+			}
+		}
+		return nil
+	})
+	return broadcast, err
+}
+
+// 获取接收者
+// 从cursor位置开始最多返回limit个接收者
+func (model *BroadcastModel) GetReceivers(id uint64, cursor, limit int) ([]int64, error) {
+	receivers := make([]int64, 0, limit)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		key := strconv.FormatUint(id, 10)
+		bucket, err := storage.GetBucketIfExists(tx, "broadcasts", "receivers", key)
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.Seek(orderedKey(uint64(cursor))); k != nil && len(receivers) < limit; k, v = c.Next() {
+			userID, err := strconv.ParseInt(string(v), 10, 64)
+			if err != nil {
+				return err
+			}
+			receivers = append(receivers, userID)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return receivers, nil
+}
+
+// 更新发送进度
+// 全部发送完成后标记为已完成
+func (model *BroadcastModel) UpdateProgress(id uint64, progress *BroadcastProgress) (*Broadcast, error) {
+	var broadcast *Broadcast
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		broadcast, err = model.getBroadcast(tx, id)
+		if err != nil {
+			return err
+		}
+
+		broadcast.Cursor = progress.Cursor
+		broadcast.Sent += progress.Sent
+		broadcast.Failed += progress.Failed
+		broadcast.Blocked += progress.Blocked
+		if broadcast.Cursor >= broadcast.Total && !broadcast.State.Finished() {
+			broadcast.State = BroadcastFinished
+		}
+		return model.putBroadcast(tx, broadcast)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return broadcast, nil
+}
+
+// 设置媒体文件ID
+// 清除已上传的文件内容
+func (model *BroadcastModel) SetFileID(id uint64, fileID string) (*Broadcast, error) {
+	var broadcast *Broadcast
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		broadcast, err = model.getBroadcast(tx, id)
+		if err != nil {
+			return err
+		}
+
+		broadcast.FileID = fileID
+		broadcast.File = nil
+		return model.putBroadcast(tx, broadcast)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return broadcast, nil
+}
+
+// 设置任务状态
+// 只允许暂停发送中的任务、恢复已暂停的任务和取消未结束的任务
+func (model *BroadcastModel) SetState(id uint64, state BroadcastState) (*Broadcast, error) {
+	var broadcast *Broadcast
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		broadcast, err = model.getBroadcast(tx, id)
+		if err != nil {
+			return err
+		}
+		if broadcast.State.Finished() {
+			return ErrBroadcastFinished
+		}
+
+		switch state {
+		case BroadcastPaused:
+			if broadcast.State != BroadcastRunning {
+				return ErrBroadcastState
+			}
+		case BroadcastRunning:
+			if broadcast.State != BroadcastPaused {
+				return ErrBroadcastState
+			}
+		case BroadcastCancelled:
+		default:
+			return ErrBroadcastState
+		}
+		broadcast.State = state
+		return model.putBroadcast(tx, broadcast)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return broadcast, nil
+}
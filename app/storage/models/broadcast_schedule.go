@@ -0,0 +1,247 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"luckybot/app/storage"
+)
+
+// 定时状态
+type ScheduleState int
+
+const (
+	_                 ScheduleState = iota
+	ScheduleActive                  // 等待执行
+	ScheduleCancelled               // 已取消
+	ScheduleDone                    // 已完成
+)
+
+// 定时广播
+type BroadcastSchedule struct {
+	ID uint64 `json:"id"` // 定时ID
+	BroadcastContent
+	Segment     *Segment      `json:"segment"`                // 受众条件
+	State       ScheduleState `json:"state"`                  // 定时状态
+	RunAt       int64         `json:"run_at"`                 // 下次执行时间
+	Interval    uint32        `json:"interval"`               // 重复间隔，0表示不重复
+	Runs        int           `json:"runs"`                   // 执行次数
+	LastRunAt   int64         `json:"last_run_at,omitempty"`  // 最近执行时间
+	LastJobID   uint64        `json:"last_job_id,omitempty"`  // 最近广播任务ID
+	LastError   string        `json:"last_error,omitempty"`   // 最近错误信息
+	CreatedAt   int64         `json:"created_at"`             // 创建时间
+	CancelledAt int64         `json:"cancelled_at,omitempty"` // 取消时间
+}
+
+var (
+	// 定时广播不存在
+	ErrScheduleNotFound = errors.New("schedule not found")
+	// 定时广播已结束
+	ErrScheduleFinished = errors.New("schedule already finished")
+)
+
+// ********************** 结构图 **********************
+// {
+//	"broadcast_schedules": {
+// 		<id>: BroadcastSchedule	// 定时广播
+//	}
+// }
+// ***************************************************
+
+// 定时广播模型
+type BroadcastScheduleModel struct {
+}
+
+// 获取定时广播
+func (model *BroadcastScheduleModel) getSchedule(tx *bolt.Tx, id uint64) (*BroadcastSchedule, error) {
+	bucket, err := storage.GetBucketIfExists(tx, "broadcast_schedules")
+	if err != nil {
+		if err != storage.ErrNoBucket {
+			return nil, err
+		}
+		return nil, ErrScheduleNotFound
+	}
+
+	jsb := bucket.Get([]byte(strconv.FormatUint(id, 10)))
+	if jsb == nil {
+		return nil, ErrScheduleNotFound
+	}
+
+	var schedule BroadcastSchedule
+	if err = json.Unmarshal(jsb, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// 保存定时广播
+func (model *BroadcastScheduleModel) putSchedule(tx *bolt.Tx, schedule *BroadcastSchedule) error {
+	bucket, err := storage.EnsureBucketExists(tx, "broadcast_schedules")
+	if err != nil {
+		return err
+	}
+
+	jsb, err := json.Marshal(schedule)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(strconv.FormatUint(schedule.ID, 10)), jsb)
+}
+
+// 创建定时广播
+func (model *BroadcastScheduleModel) NewSchedule(schedule *BroadcastSchedule) (*BroadcastSchedule, error) {
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "broadcast_schedules")
+		if err != nil {
+			return err
+		}
+
+		schedule.ID, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		schedule.State = ScheduleActive
+		schedule.CreatedAt = time.Now().UTC().Unix()
+		return model.putSchedule(tx, schedule)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// 获取定时广播列表
+// 按时间倒序返回，state为0时返回全部
+func (model *BroadcastScheduleModel) GetSchedules(state ScheduleState, offset, limit uint) ([]*BroadcastSchedule, uint, error) {
+	sum := uint(0)
+	schedules := make([]*BroadcastSchedule, 0)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "broadcast_schedules")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		for i := bucket.Sequence(); i > 0; i-- {
+			jsb := bucket.Get([]byte(strconv.FormatUint(i, 10)))
+			if jsb == nil {
+				continue
+			}
+
+			var schedule BroadcastSchedule
+			if err = json.Unmarshal(jsb, &schedule); err != nil {
+				return err
+			}
+			if state != 0 && schedule.State != state {
+				continue
+			}
+
+			sum++
+			if sum > offset && uint(len(schedules)) < limit {
+				schedules = append(schedules, &schedule)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+	return schedules, sum, nil
+}
+
+// 获取到期定时广播
+func (model *BroadcastScheduleModel) GetDue(now int64) ([]*BroadcastSchedule, error) {
+	schedules := make([]*BroadcastSchedule, 0)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "broadcast_schedules")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var schedule BroadcastSchedule
+			if err := json.Unmarshal(v, &schedule); err != nil {
+				return err
+			}
+			if schedule.State == ScheduleActive && schedule.RunAt <= now {
+				schedules = append(schedules, &schedule)
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// 记录执行结果
+// 重复的定时广播跳过错过的周期，计算下次执行时间，否则标记为已完成
+func (model *BroadcastScheduleModel) MarkRun(id uint64, jobID uint64, reason string) (*BroadcastSchedule, error) {
+	var schedule *BroadcastSchedule
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		schedule, err = model.getSchedule(tx, id)
+		if err != nil {
+			return err
+		}
+		if schedule.State != ScheduleActive {
+			return ErrScheduleFinished
+		}
+
+		now := time.Now().UTC().Unix()
+		schedule.Runs++
+		schedule.LastRunAt = now
+		schedule.LastJobID = jobID
+		schedule.LastError = reason
+		if schedule.Interval == 0 {
+			schedule.State = ScheduleDone
+		} else {
+			interval := int64(schedule.Interval)
+			if schedule.RunAt <= now {
+				schedule.RunAt += ((now-schedule.RunAt)/interval + 1) * interval
+			}
+		}
+		return model.putSchedule(tx, schedule)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// 取消定时广播
+func (model *BroadcastScheduleModel) Cancel(id uint64) (*BroadcastSchedule, error) {
+	var schedule *BroadcastSchedule
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		schedule, err = model.getSchedule(tx, id)
+		if err != nil {
+			return err
+		}
+		if schedule.State != ScheduleActive {
+			return ErrScheduleFinished
+		}
+
+		schedule.State = ScheduleCancelled
+		schedule.CancelledAt = time.Now().UTC().Unix()
+		return model.putSchedule(tx, schedule)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
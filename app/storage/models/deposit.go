@@ -21,18 +21,31 @@ func (model *DepositModel) Exist(txid string) bool {
 	return ret
 }
 
-// 添加充值记录
-func (model *DepositModel) Add(txid string, data []byte) error {
-	return storage.DB.Update(func(tx *bolt.Tx) error {
+// 添加充值记录并入账
+// 充值记录与记账凭证在同一事务中提交，防止重复入账
+func (model *DepositModel) AddAndPost(txid string, data []byte, journal *Journal) (map[int64]*Account, error) {
+	var accounts map[int64]*Account
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
 		if model.exist(tx, txid) {
 			return errors.New("repeat deposit")
 		}
 		bucket, err := storage.EnsureBucketExists(tx, "deposits")
 		if err != nil {
-			return nil
+			return err
 		}
-		return bucket.Put([]byte(txid), data)
+		if err = bucket.Put([]byte(txid), data); err != nil {
+			return err
+		}
+
+		ledger := LedgerModel{}
+		accounts, err = ledger.post(tx, journal)
+		return err
 	})
+
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
 }
 
 // 查询TxID是否存在
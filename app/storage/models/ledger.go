@@ -0,0 +1,307 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"luckybot/app/fmath"
+	"luckybot/app/storage"
+)
+
+// 账簿类型
+type Book string
+
+const (
+	BookAvailable Book = "available" // 可用余额
+	BookLocked    Book = "locked"    // 锁定余额
+	BookExternal  Book = "external"  // 外部账户
+)
+
+// 记账方向
+type Side int
+
+const (
+	_      Side = iota
+	Debit       // 借方(转出)
+	Credit      // 贷方(转入)
+)
+
+// 分录信息
+type Entry struct {
+	UserID int64         `json:"user_id"` // 用户ID
+	Book   Book          `json:"book"`    // 账簿类型
+	Side   Side          `json:"side"`    // 记账方向
+	Amount fmath.Decimal `json:"amount"`  // 金额
+}
+
+// 版本引用
+type VersionRef struct {
+	UserID    int64  `json:"user_id"`    // 用户ID
+	VersionID uint64 `json:"version_id"` // 版本ID
+}
+
+// 记账凭证
+// 每笔资金变动由借贷平衡的分录组成，与账户版本记录在同一事务内提交
+type Journal struct {
+	ID        uint64        `json:"id"`        // 凭证ID
+	Symbol    string        `json:"symbol"`    // 代币符号
+	Entries   []*Entry      `json:"entries"`   // 分录列表
+	Versions  []*VersionRef `json:"versions"`  // 关联版本
+	Timestamp int64         `json:"timestamp"` // 时间戳
+	records   []*journalRecord
+}
+
+// 版本记录
+type journalRecord struct {
+	userID  int64
+	version *Version
+}
+
+var (
+	// 借贷不平衡
+	ErrUnbalancedJournal = errors.New("unbalanced journal")
+	// 无效分录
+	ErrInvalidEntry = errors.New("invalid entry")
+)
+
+// 创建记账凭证
+func NewJournal(symbol string) *Journal {
+	return &Journal{
+		Symbol:   symbol,
+		Entries:  make([]*Entry, 0),
+		Versions: make([]*VersionRef, 0),
+	}
+}
+
+// 添加分录
+func (journal *Journal) entry(userID int64, book Book, side Side, amount fmath.Decimal) *Journal {
+	journal.Entries = append(journal.Entries, &Entry{
+		UserID: userID,
+		Book:   book,
+		Side:   side,
+		Amount: amount,
+	})
+	return journal
+}
+
+// 充值到可用余额
+func (journal *Journal) Deposit(userID int64, amount fmath.Decimal) *Journal {
+	return journal.entry(0, BookExternal, Debit, amount).
+		entry(userID, BookAvailable, Credit, amount)
+}
+
+// 从锁定余额提现
+func (journal *Journal) Withdraw(userID int64, amount fmath.Decimal) *Journal {
+	return journal.entry(userID, BookLocked, Debit, amount).
+		entry(0, BookExternal, Credit, amount)
+}
+
+// 锁定资金
+func (journal *Journal) Lock(userID int64, amount fmath.Decimal) *Journal {
+	return journal.entry(userID, BookAvailable, Debit, amount).
+		entry(userID, BookLocked, Credit, amount)
+}
+
+// 解锁资金
+func (journal *Journal) Unlock(userID int64, amount fmath.Decimal) *Journal {
+	return journal.entry(userID, BookLocked, Debit, amount).
+		entry(userID, BookAvailable, Credit, amount)
+}
+
+// 从锁定余额转账
+func (journal *Journal) TransferLocked(from, to int64, amount fmath.Decimal) *Journal {
+	return journal.entry(from, BookLocked, Debit, amount).
+		entry(to, BookAvailable, Credit, amount)
+}
+
+// 记录账户版本
+// 版本的代币符号、剩余金额、时间戳等信息在提交时填充
+func (journal *Journal) Record(userID int64, version *Version) *Journal {
+	journal.records = append(journal.records, &journalRecord{
+		userID:  userID,
+		version: version,
+	})
+	return journal
+}
+
+// 检查借贷平衡
+func (journal *Journal) check() error {
+	if len(journal.Entries) == 0 {
+		return ErrInvalidEntry
+	}
+
+	debit, credit := fmath.Zero(0), fmath.Zero(0)
+	for _, entry := range journal.Entries {
+		if entry.Amount.Sign() < 0 {
+			return ErrInvalidEntry
+		}
+		switch entry.Side {
+		case Debit:
+			debit = debit.Add(entry.Amount)
+		case Credit:
+			credit = credit.Add(entry.Amount)
+		default:
+			return ErrInvalidEntry
+		}
+	}
+
+	if debit.Cmp(credit) != 0 {
+		return ErrUnbalancedJournal
+	}
+	return nil
+}
+
+// ********************** 结构图 **********************
+// {
+//	"ledger": {
+// 		<journal_id>: Journal	// 记账凭证
+//	}
+// }
+// ***************************************************
+
+// 账本模型
+type LedgerModel struct {
+}
+
+// 提交记账凭证
+func (model *LedgerModel) Post(journal *Journal) (map[int64]*Account, error) {
+	var accounts map[int64]*Account
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		accounts, err = model.post(tx, journal)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// 获取记账凭证
+func (model *LedgerModel) GetJournal(id uint64) (*Journal, error) {
+	var journal Journal
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "ledger")
+		if err != nil {
+			return err
+		}
+
+		jsb := bucket.Get([]byte(strconv.FormatUint(id, 10)))
+		if jsb == nil {
+			return errors.New("not found")
+		}
+		return json.Unmarshal(jsb, &journal)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return &journal, nil
+}
+
+// 在事务中提交记账凭证
+func (model *LedgerModel) post(tx *bolt.Tx, journal *Journal) (map[int64]*Account, error) {
+	if err := journal.check(); err != nil {
+		return nil, err
+	}
+
+	// 更新账户余额
+	accounts := make(map[int64]*Account)
+	for _, entry := range journal.Entries {
+		if entry.Book == BookExternal {
+			continue
+		}
+
+		account, ok := accounts[entry.UserID]
+		if !ok {
+			var err error
+			account, err = getAccount(tx, entry.UserID, journal.Symbol)
+			if err != nil {
+				if err != ErrNoSuchTypeAccount || entry.Side == Debit {
+					return nil, err
+				}
+				account = &Account{
+					Symbol: journal.Symbol,
+					Amount: fmath.Zero(entry.Amount.Prec()),
+					Locked: fmath.Zero(entry.Amount.Prec()),
+				}
+			}
+			accounts[entry.UserID] = account
+		}
+
+		var balance *fmath.Decimal
+		switch entry.Book {
+		case BookAvailable:
+			balance = &account.Amount
+		case BookLocked:
+			balance = &account.Locked
+		default:
+			return nil, ErrInvalidEntry
+		}
+
+		if entry.Side == Debit {
+			if entry.Amount.Cmp(*balance) == 1 {
+				return nil, ErrInsufficientAmount
+			}
+			*balance = balance.Sub(entry.Amount)
+		} else {
+			*balance = balance.Add(entry.Amount)
+		}
+	}
+
+	for userID, account := range accounts {
+		if err := putAccount(tx, userID, account); err != nil {
+			return nil, err
+		}
+	}
+
+	// 生成凭证ID
+	bucket, err := storage.EnsureBucketExists(tx, "ledger")
+	if err != nil {
+		return nil, err
+	}
+	journal.ID, err = bucket.NextSequence()
+	if err != nil {
+		return nil, err
+	}
+	journal.Timestamp = time.Now().UTC().Unix()
+
+	// 插入账户版本
+	journal.Versions = make([]*VersionRef, 0, len(journal.records))
+	for _, record := range journal.records {
+		version := record.version
+		version.Symbol = journal.Symbol
+		version.JournalID = journal.ID
+		if account, ok := accounts[record.userID]; ok {
+			version.Amount = account.Amount
+		} else {
+			account, err := getAccount(tx, record.userID, journal.Symbol)
+			if err != nil {
+				return nil, err
+			}
+			version.Amount = account.Amount
+		}
+
+		if err = insertVersion(tx, record.userID, version); err != nil {
+			return nil, err
+		}
+		journal.Versions = append(journal.Versions, &VersionRef{
+			UserID:    record.userID,
+			VersionID: version.ID,
+		})
+	}
+
+	// 保存记账凭证
+	jsb, err := json.Marshal(journal)
+	if err != nil {
+		return nil, err
+	}
+	if err = bucket.Put([]byte(strconv.FormatUint(journal.ID, 10)), jsb); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
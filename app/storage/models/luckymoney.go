@@ -5,9 +5,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"math"
-	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/boltdb/bolt"
 	"luckybot/app/fmath"
@@ -19,32 +18,49 @@ const DefaultLuckyMoneyID = 100000
 
 // 红包信息
 type LuckyMoney struct {
-	ID         uint64     `json:"id"`          // 红包ID
-	SN         string     `json:"sn"`          // 唯一编号
-	SenderID   int64      `json:"sender_id"`   // 发送者
-	SenderName string     `json:"sender_name"` // 发送者名字
-	Asset      string     `json:"asset"`       // 资产类型
-	Amount     *big.Float `json:"amount"`      // 红包金额
-	Received   *big.Float `json:"received"`    // 领取金额
-	Number     uint32     `json:"number"`      // 红包个数
-	Lucky      bool       `json:"lucky"`       // 是否随机
-	Value      *big.Float `json:"value"`       // 单个价值
-	Active     bool       `json:"active"`      // 是否激活
-	Message    string     `json:"message"`     // 红包留言
-	Timestamp  int64      `json:"timestamp"`   // 时间戳
+	ID         uint64         `json:"id"`          // 红包ID
+	SN         string         `json:"sn"`          // 唯一编号
+	SenderID   int64          `json:"sender_id"`   // 发送者
+	SenderName string         `json:"sender_name"` // 发送者名字
+	Asset      string         `json:"asset"`       // 资产类型
+	Amount     fmath.Decimal  `json:"amount"`      // 红包金额
+	Received   fmath.Decimal  `json:"received"`    // 领取金额
+	Number     uint32         `json:"number"`      // 红包个数
+	Lucky      bool           `json:"lucky"`       // 是否随机
+	Value      *fmath.Decimal `json:"value"`       // 单个价值
+	Active     bool           `json:"active"`      // 是否激活
+	Message    string         `json:"message"`     // 红包留言
+	Timestamp  int64          `json:"timestamp"`   // 时间戳
+
+	Recipients []LuckyMoneyRecipient `json:"recipients,omitempty"` // 专属领取人
 }
 
-// 标准化
-func (luckymoney *LuckyMoney) Normalization() {
-	if luckymoney.Value != nil {
-		luckymoney.Value.SetPrec(fmath.Prec())
-	}
-	if luckymoney.Amount != nil {
-		luckymoney.Amount.SetPrec(fmath.Prec())
+// 专属领取人
+// 按用户ID或用户名指定，用户名不区分大小写
+type LuckyMoneyRecipient struct {
+	UserID   int64  `json:"user_id,omitempty"`  // 用户ID
+	UserName string `json:"username,omitempty"` // 用户名
+}
+
+// 是否专属红包
+func (luckyMoney *LuckyMoney) Exclusive() bool {
+	return len(luckyMoney.Recipients) > 0
+}
+
+// 是否允许领取
+func (luckyMoney *LuckyMoney) CanReceive(userID int64, userName string) bool {
+	if !luckyMoney.Exclusive() {
+		return true
 	}
-	if luckymoney.Received != nil {
-		luckymoney.Received.SetPrec(fmath.Prec())
+	for _, recipient := range luckyMoney.Recipients {
+		if recipient.UserID != 0 && recipient.UserID == userID {
+			return true
+		}
+		if len(recipient.UserName) > 0 && strings.EqualFold(recipient.UserName, userName) {
+			return true
+		}
 	}
+	return false
 }
 
 // 红包用户
@@ -55,17 +71,10 @@ type LuckyMoneyUser struct {
 
 // 红包记录
 type LuckyMoneyHistory struct {
-	Value *big.Float      `json:"value"`          // 红包金额
+	Value fmath.Decimal   `json:"value"`          // 红包金额
 	User  *LuckyMoneyUser `json:"user,omitempty"` // 用户信息
 }
 
-// 标准化
-func (history *LuckyMoneyHistory) Normalization() {
-	if history.Value != nil {
-		history.Value.SetPrec(fmath.Prec())
-	}
-}
-
 var (
 	// 领完了
 	ErrNothingLeft = errors.New("nothing left")
@@ -79,6 +88,8 @@ var (
 	ErrPermissionDenied = errors.New("permission denied")
 	// 红包已过期
 	ErrLuckyMoneydExpired = errors.New("lucky money expired")
+	// 不是专属领取人
+	ErrNotRecipient = errors.New("not recipient")
 )
 
 // ********************** 结构图 **********************
@@ -90,7 +101,7 @@ var (
 // 			"best": 0,					// 手气最佳序列
 // 			"base": types.LuckyMoney	// 红包基本信息
 //			"users": {					// 红包已领用户
-//				"user_id": ""
+//				"user_id": "seq"		// 领取序列
 //			}
 // 			"history": {				// 红包领取记录
 // 				"seq": types.LuckyMoneyHistory
@@ -167,10 +178,10 @@ func (model *LuckyMoneyModel) moveToUserHistory(tx *bolt.Tx, usdeID int64, sid s
 }
 
 // 创建领取记录
-func (model *LuckyMoneyModel) insertHistory(tx *bolt.Tx, sid string, luckyMoneyArr []*big.Float) (int, int, error) {
+func (model *LuckyMoneyModel) insertHistory(tx *bolt.Tx, sid string, luckyMoneyArr []fmath.Decimal) (int, int, error) {
 
 	worstSeq, bestSeq := 0, 0
-	minValue, maxValue := big.NewFloat(math.MaxFloat64), big.NewFloat(0)
+	var minValue, maxValue fmath.Decimal
 	bucket, err := storage.EnsureBucketExists(tx, "luckymoney", sid, "history")
 	if err != nil {
 		return 0, 0, err
@@ -193,12 +204,12 @@ func (model *LuckyMoneyModel) insertHistory(tx *bolt.Tx, sid string, luckyMoneyA
 			return 0, 0, err
 		}
 
-		if luckyMoneyArr[i].Cmp(minValue) == -1 {
+		if worstSeq == 0 || luckyMoneyArr[i].Cmp(minValue) == -1 {
 			minValue = luckyMoneyArr[i]
 			worstSeq = int(seq)
 		}
 
-		if luckyMoneyArr[i].Cmp(maxValue) == 1 {
+		if bestSeq == 0 || luckyMoneyArr[i].Cmp(maxValue) == 1 {
 			maxValue = luckyMoneyArr[i]
 			bestSeq = int(seq)
 		}
@@ -207,35 +218,35 @@ func (model *LuckyMoneyModel) insertHistory(tx *bolt.Tx, sid string, luckyMoneyA
 }
 
 // 领取红包
-func (model *LuckyMoneyModel) receiveLuckyMoney(tx *bolt.Tx, sid string, seq int, user *LuckyMoneyUser) (*big.Float, error) {
+func (model *LuckyMoneyModel) receiveLuckyMoney(tx *bolt.Tx, sid string, seq int, user *LuckyMoneyUser) (fmath.Decimal, error) {
 
+	var history LuckyMoneyHistory
 	bucket, err := storage.GetBucketIfExists(tx, "luckymoney", sid, "history")
 	if err != nil {
-		return nil, err
+		return history.Value, err
 	}
 
-	var history LuckyMoneyHistory
 	key := []byte(strconv.Itoa(seq))
 	jsb := bucket.Get(key)
 	if err = json.Unmarshal(jsb, &history); err != nil {
-		return nil, err
+		return history.Value, err
 	}
-	history.Normalization()
 	history.User = user
 
 	jsb, err = json.Marshal(&history)
 	if err != nil {
-		return nil, err
+		return history.Value, err
 	}
 
 	if err = bucket.Put(key, jsb); err != nil {
-		return nil, err
+		return history.Value, err
 	}
 	return history.Value, nil
 }
 
 // 创建新红包
-func (model *LuckyMoneyModel) NewLuckyMoney(data *LuckyMoney, luckyMoneyArr []*big.Float) (*LuckyMoney, error) {
+// 红包总金额在同一事务中从发送者可用余额锁定
+func (model *LuckyMoneyModel) NewLuckyMoney(data *LuckyMoney, luckyMoneyArr []fmath.Decimal) (*LuckyMoney, error) {
 	err := storage.DB.Update(func(tx *bolt.Tx) error {
 		// 生成红包ID
 		rootBucket, err := storage.EnsureBucketExists(tx, "luckymoney")
@@ -260,7 +271,7 @@ func (model *LuckyMoneyModel) NewLuckyMoney(data *LuckyMoney, luckyMoneyArr []*b
 		data.SN = sn
 
 		// 序列化数据
-		data.Received = big.NewFloat(0)
+		data.Received = fmath.Zero(data.Amount.Prec())
 		data.Active = false
 		jsb, err := json.Marshal(data)
 		if err != nil {
@@ -290,6 +301,19 @@ func (model *LuckyMoneyModel) NewLuckyMoney(data *LuckyMoney, luckyMoneyArr []*b
 			return err
 		}
 
+		// 锁定红包资金
+		amount := fmath.Sum(data.Amount.Prec(), luckyMoneyArr...)
+		journal := NewJournal(data.Asset).Lock(data.SenderID, amount).
+			Record(data.SenderID, &Version{
+				Locked:          &amount,
+				Reason:          ReasonGive,
+				RefLuckyMoneyID: &data.ID,
+			})
+		ledger := LedgerModel{}
+		if _, err = ledger.post(tx, journal); err != nil {
+			return err
+		}
+
 		// 插入已领取序列
 		err = bucket.Put([]byte("seq"), []byte("0"))
 		if err != nil {
@@ -343,27 +367,70 @@ func (model *LuckyMoneyModel) IsExpired(id uint64) bool {
 }
 
 // 设置过期
-func (model *LuckyMoneyModel) SetExpired(id uint64) error {
-	// 获取红包信息
-	luckyMoney, _, err := model.GetLuckyMoney(id)
-	if err != nil {
-		return nil
-	}
-
+// 红包剩余金额在同一事务中退还给发送者，返回退还记录
+func (model *LuckyMoneyModel) SetExpired(id uint64) (*Version, error) {
+	var version *Version
 	sid := strconv.FormatUint(id, 10)
-	return storage.DB.Update(func(tx *bolt.Tx) error {
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "luckymoney", sid)
+		if err != nil {
+			return err
+		}
+		if bucket.Get([]byte("expired")) != nil {
+			return ErrLuckyMoneydExpired
+		}
+
+		// 获取红包信息
+		var base LuckyMoney
+		jsb := bucket.Get([]byte("base"))
+		if err = json.Unmarshal(jsb, &base); err != nil {
+			return err
+		}
+		numReceived, err := strconv.Atoi(string(bucket.Get([]byte("seq"))))
+		if err != nil {
+			return err
+		}
+
 		// 添加用户历史
-		if err = model.moveToUserHistory(tx, luckyMoney.SenderID, sid); err != nil {
+		if err = model.moveToUserHistory(tx, base.SenderID, sid); err != nil {
 			return err
 		}
 
 		// 标记红包过期
-		bucket, err := storage.GetBucketIfExists(tx, "luckymoney", sid)
-		if err != nil {
+		if err = bucket.Put([]byte("expired"), []byte("true")); err != nil {
 			return err
 		}
-		return bucket.Put([]byte("expired"), []byte("true"))
+
+		// 计算红包余额
+		if uint32(numReceived) >= base.Number {
+			return nil
+		}
+		balance := base.Amount.Sub(base.Received)
+		if !base.Lucky {
+			balance = base.Amount.Mul(int64(base.Number)).Sub(base.Received)
+		}
+		if balance.Sign() <= 0 {
+			return nil
+		}
+
+		// 返还红包余额
+		locked := balance.Neg()
+		version = &Version{
+			Locked:          &locked,
+			Reason:          ReasonGiveBack,
+			RefLuckyMoneyID: &base.ID,
+		}
+		journal := NewJournal(base.Asset).Unlock(base.SenderID, balance).
+			Record(base.SenderID, version)
+		ledger := LedgerModel{}
+		_, err = ledger.post(tx, journal)
+		return err
 	})
+
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
 }
 
 // 是否已领取
@@ -439,7 +506,6 @@ func (model *LuckyMoneyModel) GetLuckyMoney(id uint64) (*LuckyMoney, uint32, err
 		if err = json.Unmarshal(jsb, &base); err != nil {
 			return err
 		}
-		base.Normalization()
 
 		// 已领取数量
 		seq := bucket.Get([]byte("seq"))
@@ -487,18 +553,20 @@ func (model *LuckyMoneyModel) GetLuckyMoneyIDBySN(sn string) (uint64, error) {
 }
 
 // 领取红包
-func (model *LuckyMoneyModel) ReceiveLuckyMoney(id uint64, userID int64, firstName string) (*big.Float, int, error) {
+// 记录领取者、从发送者锁定余额转账给领取者并写入双方账户版本在同一事务中完成，
+// 同一用户重复领取同一红包返回ErrRepeatReceive，专属红包的其他用户领取返回ErrNotRecipient
+func (model *LuckyMoneyModel) ReceiveLuckyMoney(id uint64, userID int64, userName, firstName string) (fmath.Decimal, int, error) {
+	var value fmath.Decimal
 	received, err := model.IsReceived(id, userID)
 	if err != nil {
-		return nil, 0, err
+		return value, 0, err
 	}
 
 	if received {
-		return nil, 0, ErrRepeatReceive
+		return value, 0, ErrRepeatReceive
 	}
 
 	count := 0
-	value := big.NewFloat(0)
 	sid := strconv.FormatUint(id, 10)
 	err = storage.DB.Update(func(tx *bolt.Tx) error {
 		bucket, err := storage.GetBucketIfExists(tx, "luckymoney", sid)
@@ -524,7 +592,11 @@ func (model *LuckyMoneyModel) ReceiveLuckyMoney(id uint64, userID int64, firstNa
 		if err = json.Unmarshal(jsb, &base); err != nil {
 			return err
 		}
-		base.Normalization()
+
+		// 是否专属领取人
+		if !base.CanReceive(userID, userName) {
+			return ErrNotRecipient
+		}
 
 		if uint32(numReceived) >= base.Number {
 			return ErrNothingLeft
@@ -554,7 +626,30 @@ func (model *LuckyMoneyModel) ReceiveLuckyMoney(id uint64, userID int64, firstNa
 		if err != nil {
 			return err
 		}
-		base.Received = fmath.Add(base.Received, value)
+		base.Received = base.Received.Add(value)
+
+		// 转移红包资金
+		locked := value.Neg()
+		journal := NewJournal(base.Asset).TransferLocked(base.SenderID, userID, value).
+			Record(base.SenderID, &Version{
+				Balance:         &locked,
+				Locked:          &locked,
+				Reason:          ReasonClaimed,
+				RefLuckyMoneyID: &base.ID,
+				RefUserID:       &userID,
+				RefUserName:     &firstName,
+			}).
+			Record(userID, &Version{
+				Balance:         &value,
+				Reason:          ReasonReceive,
+				RefLuckyMoneyID: &base.ID,
+				RefUserID:       &base.SenderID,
+				RefUserName:     &base.SenderName,
+			})
+		ledger := LedgerModel{}
+		if _, err = ledger.post(tx, journal); err != nil {
+			return err
+		}
 
 		// 更新红包信息
 		if jsb, err = json.Marshal(&base); err != nil {
@@ -563,7 +658,7 @@ func (model *LuckyMoneyModel) ReceiveLuckyMoney(id uint64, userID int64, firstNa
 		if err = bucket.Put([]byte("base"), jsb); err != nil {
 			return err
 		}
-		if err = usersBucket.Put(key, []byte("")); err != nil {
+		if err = usersBucket.Put(key, []byte(strconv.Itoa(newSeq))); err != nil {
 			return err
 		}
 		if err = bucket.Put([]byte("seq"), []byte(strconv.Itoa(newSeq))); err != nil {
@@ -582,7 +677,7 @@ func (model *LuckyMoneyModel) ReceiveLuckyMoney(id uint64, userID int64, firstNa
 	})
 
 	if err != nil {
-		return nil, 0, err
+		return value, 0, err
 	}
 	return value, count, nil
 }
@@ -607,7 +702,6 @@ func (model *LuckyMoneyModel) GetReceiveHistory(id uint64) ([]*LuckyMoneyHistory
 				if err = json.Unmarshal(v, &item); err != nil {
 					return err
 				}
-				item.Normalization()
 
 				if item.User == nil {
 					return nil
@@ -661,8 +755,6 @@ func (model *LuckyMoneyModel) GetBestAndWorst(id uint64) (*LuckyMoneyHistory, *L
 			return err
 		}
 
-		best.Normalization()
-		worst.Normalization()
 		return nil
 	})
 
@@ -689,7 +781,6 @@ func (model *LuckyMoneyModel) Foreach(startID uint64, callback func(*LuckyMoney)
 				if err = json.Unmarshal(jsb, &base); err != nil {
 					continue
 				}
-				base.Normalization()
 
 				if callback != nil {
 					callback(&base)
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"luckybot/app/fmath"
+	"luckybot/app/storage"
+)
+
+// ********************** 结构图 **********************
+// {
+//	"migrations": {
+// 		<name>: <timestamp>	// 已执行的迁移
+//	}
+// }
+// ***************************************************
+
+// 金额定点小数迁移
+const migrationDecimal = "decimal"
+
+// 迁移模型
+type MigrationModel struct {
+}
+
+// 迁移金额为定点小数
+// 旧版本使用big.Float存储金额，此处按资产精度四舍五入后重新写入，只会执行一次
+func (model *MigrationModel) MigrateDecimal(precisions map[string]int) (bool, error) {
+	migrated := false
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "migrations")
+		if err != nil {
+			return err
+		}
+		if bucket.Get([]byte(migrationDecimal)) != nil {
+			return nil
+		}
+
+		// 获取资产精度
+		precision := func(symbol string) int {
+			if prec, ok := precisions[symbol]; ok {
+				return prec
+			}
+			return fmath.MaxPrecision
+		}
+
+		// 迁移账户信息
+		err = foreachSubBucket(tx, func(userBucket *bolt.Bucket) error {
+			return rewriteBucket(userBucket, func(k, v []byte) ([]byte, error) {
+				return migrateAmounts(v, precision(string(k)), "amount", "locked")
+			})
+		}, "accounts")
+		if err != nil {
+			return err
+		}
+
+		// 迁移账户版本
+		err = foreachSubBucket(tx, func(userBucket *bolt.Bucket) error {
+			return rewriteBucket(userBucket, func(k, v []byte) ([]byte, error) {
+				var version struct {
+					Symbol string `json:"symbol"`
+				}
+				if err := json.Unmarshal(v, &version); err != nil {
+					return nil, err
+				}
+				return migrateAmounts(v, precision(version.Symbol), "balance", "locked", "fee", "amount")
+			})
+		}, "account_versions")
+		if err != nil {
+			return err
+		}
+
+		// 迁移红包信息
+		err = foreachSubBucket(tx, func(luckyMoneyBucket *bolt.Bucket) error {
+			jsb := luckyMoneyBucket.Get([]byte("base"))
+			if jsb == nil {
+				return nil
+			}
+
+			var base struct {
+				Asset string `json:"asset"`
+			}
+			if err := json.Unmarshal(jsb, &base); err != nil {
+				return err
+			}
+			prec := precision(base.Asset)
+
+			jsb, err := migrateAmounts(jsb, prec, "amount", "received", "value")
+			if err != nil {
+				return err
+			}
+			if err = luckyMoneyBucket.Put([]byte("base"), jsb); err != nil {
+				return err
+			}
+
+			historyBucket := luckyMoneyBucket.Bucket([]byte("history"))
+			if historyBucket == nil {
+				return nil
+			}
+			return rewriteBucket(historyBucket, func(k, v []byte) ([]byte, error) {
+				return migrateAmounts(v, prec, "value")
+			})
+		}, "luckymoney")
+		if err != nil {
+			return err
+		}
+
+		migrated = true
+		timestamp := strconv.FormatInt(time.Now().UTC().Unix(), 10)
+		return bucket.Put([]byte(migrationDecimal), []byte(timestamp))
+	})
+
+	if err != nil {
+		return false, err
+	}
+	return migrated, nil
+}
+
+// 遍历子桶
+func foreachSubBucket(tx *bolt.Tx, callback func(*bolt.Bucket) error, args ...string) error {
+	bucket, err := storage.GetBucketIfExists(tx, args...)
+	if err != nil {
+		if err != storage.ErrNoBucket {
+			return err
+		}
+		return nil
+	}
+
+	keys := make([][]byte, 0)
+	bucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			keys = append(keys, append([]byte{}, k...))
+		}
+		return nil
+	})
+
+	for _, key := range keys {
+		if err = callback(bucket.Bucket(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 重写桶内数据
+func rewriteBucket(bucket *bolt.Bucket, convert func(k, v []byte) ([]byte, error)) error {
+	keys := make([][]byte, 0)
+	values := make([][]byte, 0)
+	bucket.ForEach(func(k, v []byte) error {
+		if v != nil {
+			keys = append(keys, append([]byte{}, k...))
+			values = append(values, append([]byte{}, v...))
+		}
+		return nil
+	})
+
+	for i := range keys {
+		jsb, err := convert(keys[i], values[i])
+		if err != nil {
+			return err
+		}
+		if err = bucket.Put(keys[i], jsb); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 转换金额字段
+func migrateAmounts(data []byte, precision int, fields ...string) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		raw, ok := object[field]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+
+		value, ok := fmath.ParseRound(strings.Trim(string(raw), `"`), precision)
+		if !ok {
+			return nil, fmath.ErrInvalidDecimal
+		}
+
+		jsb, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		object[field] = jsb
+	}
+	return json.Marshal(object)
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strconv"
+
+	"github.com/boltdb/bolt"
+	"luckybot/app/storage"
+)
+
+// ********************** 结构图 **********************
+// {
+//	"poller": {
+// 		<bot_id>: uint32	// 下一个更新ID
+//	}
+// }
+// ***************************************************
+
+// 轮询模型
+type PollerModel struct {
+}
+
+// 获取更新偏移
+func (*PollerModel) GetOffset(botID int64) (uint32, error) {
+	var offset uint32
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "poller")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		value := bucket.Get([]byte(strconv.FormatInt(botID, 10)))
+		if value == nil {
+			return nil
+		}
+		n, err := strconv.ParseUint(string(value), 10, 32)
+		if err != nil {
+			return err
+		}
+		offset = uint32(n)
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// 保存更新偏移
+func (*PollerModel) SetOffset(botID int64, offset uint32) error {
+	return storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "poller")
+		if err != nil {
+			return err
+		}
+		key := []byte(strconv.FormatInt(botID, 10))
+		return bucket.Put(key, []byte(strconv.FormatUint(uint64(offset), 10)))
+	})
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/zhangpanyi/basebot/telegram/methods"
+	"luckybot/app/storage"
+)
+
+// 媒体类型
+const (
+	MediaPhoto    = "photo"    // 图片
+	MediaDocument = "document" // 文档
+)
+
+// 不解析格式
+const ParseModeNone = "None"
+
+// 推送消息
+type PushMessage struct {
+	ID          uint64                        `json:"id"`                   // 消息ID
+	Receiver    int64                         `json:"receiver"`             // 接收者
+	Text        string                        `json:"text"`                 // 文本或媒体标题
+	Markdown    bool                          `json:"markdown"`             // MarkDown渲染
+	ParseMode   string                        `json:"parse_mode,omitempty"` // 解析模式，优先于Markdown
+	Media       string                        `json:"media,omitempty"`      // 媒体类型
+	FileID      string                        `json:"file_id,omitempty"`    // 媒体文件ID
+	Markup      *methods.InlineKeyboardMarkup `json:"markup,omitempty"`     // Reply Markup
+	Attempts    int                           `json:"attempts"`             // 尝试次数
+	NextAttempt int64                         `json:"next_attempt"`         // 下次尝试时间
+	LastError   string                        `json:"last_error,omitempty"` // 最近错误信息
+	CreatedAt   int64                         `json:"created_at"`           // 创建时间
+}
+
+// 获取解析模式
+func (msg *PushMessage) GetParseMode() string {
+	switch {
+	case msg.ParseMode == ParseModeNone:
+		return ""
+	case len(msg.ParseMode) > 0:
+		return msg.ParseMode
+	case msg.Markdown:
+		return methods.ParseModeMarkdown
+	}
+	return ""
+}
+
+// 推送消息不存在
+var ErrPushMessageNotFound = errors.New("push message not found")
+
+// ********************** 结构图 **********************
+// {
+//	"push_queue": {
+// 		"pending": {
+// 			<id>: PushMessage	// 待推送消息
+// 		},
+// 		"dead": {
+// 			<id>: PushMessage	// 推送失败消息
+// 		}
+//	}
+// }
+// ***************************************************
+
+// 推送队列模型
+type PushQueueModel struct {
+}
+
+// 生成有序键
+// 固定宽度保证游标按ID顺序遍历
+func orderedKey(id uint64) []byte {
+	return []byte(fmt.Sprintf("%020d", id))
+}
+
+// 添加消息
+func (model *PushQueueModel) Push(msg *PushMessage) (*PushMessage, error) {
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "push_queue", "pending")
+		if err != nil {
+			return err
+		}
+
+		msg.ID, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		msg.CreatedAt = time.Now().UTC().Unix()
+		if msg.NextAttempt == 0 {
+			msg.NextAttempt = msg.CreatedAt
+		}
+		jsb, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(orderedKey(msg.ID), jsb)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// 获取到期消息
+// 按ID顺序返回到期的消息，skip返回true的消息将被跳过
+func (model *PushQueueModel) GetDue(now int64, limit int, skip func(uint64) bool) ([]*PushMessage, error) {
+	messages := make([]*PushMessage, 0)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "push_queue", "pending")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil && len(messages) < limit; k, v = cursor.Next() {
+			var msg PushMessage
+			if err = json.Unmarshal(v, &msg); err != nil {
+				return err
+			}
+			if msg.NextAttempt > now || (skip != nil && skip(msg.ID)) {
+				continue
+			}
+			messages = append(messages, &msg)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// 移除消息
+func (model *PushQueueModel) Remove(id uint64) error {
+	return storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "push_queue", "pending")
+		if err != nil {
+			return err
+		}
+		return bucket.Delete(orderedKey(id))
+	})
+}
+
+// 稍后重试
+func (model *PushQueueModel) Retry(id uint64, nextAttempt int64, reason string) error {
+	return model.update(id, func(bucket *bolt.Bucket, msg *PushMessage) error {
+		msg.Attempts++
+		msg.NextAttempt = nextAttempt
+		msg.LastError = reason
+		jsb, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(orderedKey(id), jsb)
+	})
+}
+
+// 移入死信
+func (model *PushQueueModel) DeadLetter(id uint64, reason string) error {
+	return model.update(id, func(bucket *bolt.Bucket, msg *PushMessage) error {
+		dead, err := storage.EnsureBucketExists(bucket.Tx(), "push_queue", "dead")
+		if err != nil {
+			return err
+		}
+
+		msg.Attempts++
+		msg.LastError = reason
+		jsb, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if err = dead.Put(orderedKey(id), jsb); err != nil {
+			return err
+		}
+		return bucket.Delete(orderedKey(id))
+	})
+}
+
+// 获取队列长度
+func (model *PushQueueModel) GetDepth() (int, int, error) {
+	var pending, dead int
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		if bucket, err := storage.GetBucketIfExists(tx, "push_queue", "pending"); err == nil {
+			pending = bucket.Stats().KeyN
+		} else if err != storage.ErrNoBucket {
+			return err
+		}
+		if bucket, err := storage.GetBucketIfExists(tx, "push_queue", "dead"); err == nil {
+			dead = bucket.Stats().KeyN
+		} else if err != storage.ErrNoBucket {
+			return err
+		}
+		return nil
+	})
+	return pending, dead, err
+}
+
+// 获取死信列表
+// 按时间倒序返回
+func (model *PushQueueModel) GetDeadLetters(offset, limit uint) ([]*PushMessage, error) {
+	messages := make([]*PushMessage, 0)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "push_queue", "dead")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		var idx uint
+		cursor := bucket.Cursor()
+		for k, v := cursor.Last(); k != nil && uint(len(messages)) < limit; k, v = cursor.Prev() {
+			if idx++; idx <= offset {
+				continue
+			}
+			var msg PushMessage
+			if err = json.Unmarshal(v, &msg); err != nil {
+				return err
+			}
+			messages = append(messages, &msg)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// 更新消息
+func (model *PushQueueModel) update(id uint64, fn func(*bolt.Bucket, *PushMessage) error) error {
+	return storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "push_queue", "pending")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return ErrPushMessageNotFound
+		}
+
+		jsb := bucket.Get(orderedKey(id))
+		if jsb == nil {
+			return ErrPushMessageNotFound
+		}
+		var msg PushMessage
+		if err = json.Unmarshal(jsb, &msg); err != nil {
+			return err
+		}
+		return fn(bucket, &msg)
+	})
+}
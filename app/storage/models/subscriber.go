@@ -1,19 +1,146 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"luckybot/app/storage"
 )
 
+// 订户信息
+type Subscriber struct {
+	UserID       int64  `json:"user_id"`                 // 用户ID
+	UserName     string `json:"username,omitempty"`      // 用户名
+	LanguageCode string `json:"language_code,omitempty"` // 语言代码
+	Language     string `json:"language,omitempty"`      // 选择的语言
+	Referral     string `json:"referral,omitempty"`      // 推荐来源
+	FirstSeen    int64  `json:"first_seen,omitempty"`    // 首次活跃时间
+	LastSeen     int64  `json:"last_seen,omitempty"`     // 最近活跃时间
+	Blocked      bool   `json:"blocked,omitempty"`       // 屏蔽机器人
+	BlockedAt    int64  `json:"blocked_at,omitempty"`    // 屏蔽时间
+}
+
+// 订户过滤条件
+type SubscriberFilter struct {
+	Blocked      *bool  `json:"blocked"`       // 屏蔽状态
+	LanguageCode string `json:"language_code"` // 语言代码
+	Referral     string `json:"referral"`      // 推荐来源
+	SeenSince    int64  `json:"seen_since"`    // 最近活跃时间不早于
+	Sort         string `json:"sort"`          // 排序字段
+	Desc         bool   `json:"desc"`          // 是否倒序
+}
+
+// 排序字段
+const (
+	SortByUserID    = "user_id"    // 用户ID
+	SortByFirstSeen = "first_seen" // 首次活跃时间
+	SortByLastSeen  = "last_seen"  // 最近活跃时间
+)
+
+// 活跃时间更新间隔
+const touchInterval = 60
+
+// 排序字段无效
+var ErrInvalidSort = errors.New("invalid sort field")
+
+// 订户不存在
+var ErrSubscriberNotFound = errors.New("subscriber not found")
+
+// 旧版屏蔽标记
+const subscriberBlocked = "blocked"
+
+// ********************** 结构图 **********************
+// {
+//	"subscribers": {
+// 		<user_id>: Subscriber	// 订户信息
+//	}
+// }
+// ***************************************************
+
 // 订户模型
 type SubscriberModel struct {
 }
 
+// 解析订户信息
+// 兼容旧版的空值和屏蔽标记
+func decodeSubscriber(k, v []byte) (*Subscriber, error) {
+	userID, err := strconv.ParseInt(string(k), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriber := Subscriber{UserID: userID}
+	switch string(v) {
+	case "":
+	case subscriberBlocked:
+		subscriber.Blocked = true
+	default:
+		if err = json.Unmarshal(v, &subscriber); err != nil {
+			return nil, err
+		}
+		subscriber.UserID = userID
+	}
+	return &subscriber, nil
+}
+
+// 保存订户信息
+func putSubscriber(bucket *bolt.Bucket, subscriber *Subscriber) error {
+	jsb, err := json.Marshal(subscriber)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(strconv.FormatInt(subscriber.UserID, 10)), jsb)
+}
+
+// 获取订户信息
+func (*SubscriberModel) GetSubscriber(userID int64) (*Subscriber, error) {
+	var subscriber *Subscriber
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "subscribers")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return ErrSubscriberNotFound
+		}
+
+		key := []byte(strconv.FormatInt(userID, 10))
+		value := bucket.Get(key)
+		if value == nil {
+			return ErrSubscriberNotFound
+		}
+		subscriber, err = decodeSubscriber(key, value)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return subscriber, nil
+}
+
 // 获取订阅者
-func (*SubscriberModel) GetSubscribers() ([]int64, error) {
-	var subscribers []int64
+func (model *SubscriberModel) GetSubscribers() ([]int64, error) {
+	records, err := model.GetSubscriberRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	subscribers := make([]int64, 0, len(records))
+	for _, subscriber := range records {
+		subscribers = append(subscribers, subscriber.UserID)
+	}
+	return subscribers, nil
+}
+
+// 获取订户信息列表
+func (*SubscriberModel) GetSubscriberRecords() ([]*Subscriber, error) {
+	subscribers := make([]*Subscriber, 0)
 	err := storage.DB.View(func(tx *bolt.Tx) error {
 		bucket, err := storage.GetBucketIfExists(tx, "subscribers")
 		if err != nil {
@@ -23,15 +150,13 @@ func (*SubscriberModel) GetSubscribers() ([]int64, error) {
 			return nil
 		}
 
-		subscribers = make([]int64, 0, bucket.Stats().KeyN)
-		bucket.ForEach(func(k, v []byte) error {
-			userID, err := strconv.ParseInt(string(k), 10, 64)
+		return bucket.ForEach(func(k, v []byte) error {
+			subscriber, err := decodeSubscriber(k, v)
 			if err == nil {
-				subscribers = append(subscribers, userID)
+				subscribers = append(subscribers, subscriber)
 			}
 			return nil
 		})
-		return nil
 	})
 
 	if err != nil {
@@ -40,22 +165,174 @@ func (*SubscriberModel) GetSubscribers() ([]int64, error) {
 	return subscribers, nil
 }
 
-// 添加订阅者
-func (*SubscriberModel) AddSubscriber(userID int64) error {
+// 记录订户活跃
+// 新订户记录首次活跃时间和推荐来源，已屏蔽的订户重新活跃后恢复，
+// 资料未变化时每隔一段时间才更新最近活跃时间
+func (*SubscriberModel) Touch(userID int64, userName, languageCode, referral string) error {
 	return storage.DB.Batch(func(tx *bolt.Tx) error {
 		bucket, err := storage.EnsureBucketExists(tx, "subscribers")
 		if err != nil {
 			return err
 		}
 
-		subscriber := strconv.FormatInt(userID, 10)
-		if bucket.Get([]byte(subscriber)) != nil {
+		now := time.Now().UTC().Unix()
+		key := []byte(strconv.FormatInt(userID, 10))
+		subscriber := &Subscriber{UserID: userID, Referral: referral}
+		if value := bucket.Get(key); value != nil {
+			if subscriber, err = decodeSubscriber(key, value); err != nil {
+				return err
+			}
+		}
+
+		changed := subscriber.FirstSeen == 0 || subscriber.Blocked ||
+			now-subscriber.LastSeen >= touchInterval
+		if len(userName) > 0 && subscriber.UserName != userName {
+			subscriber.UserName = userName
+			changed = true
+		}
+		if len(languageCode) > 0 && subscriber.LanguageCode != languageCode {
+			subscriber.LanguageCode = languageCode
+			changed = true
+		}
+		if !changed {
 			return nil
 		}
-		return bucket.Put([]byte(subscriber), []byte(""))
+
+		if subscriber.FirstSeen == 0 {
+			subscriber.FirstSeen = now
+		}
+		subscriber.LastSeen = now
+		subscriber.Blocked = false
+		subscriber.BlockedAt = 0
+		return putSubscriber(bucket, subscriber)
 	})
 }
 
+// 设置屏蔽状态
+// 用户屏蔽机器人后不再向其广播，再次发送消息时恢复
+func (*SubscriberModel) SetBlocked(userID int64, blocked bool) error {
+	return storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "subscribers")
+		if err != nil {
+			return err
+		}
+
+		key := []byte(strconv.FormatInt(userID, 10))
+		subscriber := &Subscriber{UserID: userID}
+		if value := bucket.Get(key); value != nil {
+			if subscriber, err = decodeSubscriber(key, value); err != nil {
+				return err
+			}
+		}
+		if subscriber.Blocked == blocked {
+			return nil
+		}
+
+		subscriber.Blocked = blocked
+		subscriber.BlockedAt = 0
+		if blocked {
+			subscriber.BlockedAt = time.Now().UTC().Unix()
+		}
+		return putSubscriber(bucket, subscriber)
+	})
+}
+
+// 设置语言
+func (*SubscriberModel) SetLanguage(userID int64, language string) error {
+	return storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "subscribers")
+		if err != nil {
+			return err
+		}
+
+		key := []byte(strconv.FormatInt(userID, 10))
+		subscriber := &Subscriber{UserID: userID}
+		if value := bucket.Get(key); value != nil {
+			if subscriber, err = decodeSubscriber(key, value); err != nil {
+				return err
+			}
+		}
+		if subscriber.Language == language {
+			return nil
+		}
+
+		subscriber.Language = language
+		return putSubscriber(bucket, subscriber)
+	})
+}
+
+// 查询订户
+// 按条件过滤排序后分页返回，同时返回满足条件的总数
+func (model *SubscriberModel) QuerySubscribers(filter *SubscriberFilter, offset, limit uint) ([]*Subscriber, uint, error) {
+	var less func(a, b *Subscriber) bool
+	switch filter.Sort {
+	case "", SortByUserID:
+		less = func(a, b *Subscriber) bool { return a.UserID < b.UserID }
+	case SortByFirstSeen:
+		less = func(a, b *Subscriber) bool { return a.FirstSeen < b.FirstSeen }
+	case SortByLastSeen:
+		less = func(a, b *Subscriber) bool { return a.LastSeen < b.LastSeen }
+	default:
+		return nil, 0, ErrInvalidSort
+	}
+
+	records, err := model.GetSubscriberRecords()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 过滤订户
+	subscribers := make([]*Subscriber, 0, len(records))
+	for _, subscriber := range records {
+		if filter.Blocked != nil && subscriber.Blocked != *filter.Blocked {
+			continue
+		}
+		if len(filter.LanguageCode) > 0 && !strings.EqualFold(subscriber.LanguageCode, filter.LanguageCode) {
+			continue
+		}
+		if len(filter.Referral) > 0 && subscriber.Referral != filter.Referral {
+			continue
+		}
+		if filter.SeenSince > 0 && subscriber.LastSeen < filter.SeenSince {
+			continue
+		}
+		subscribers = append(subscribers, subscriber)
+	}
+
+	// 排序分页
+	sort.SliceStable(subscribers, func(i, j int) bool {
+		if filter.Desc {
+			return less(subscribers[j], subscribers[i])
+		}
+		return less(subscribers[i], subscribers[j])
+	})
+	sum := uint(len(subscribers))
+	if offset >= sum {
+		return []*Subscriber{}, sum, nil
+	}
+	end := offset + limit
+	if end > sum {
+		end = sum
+	}
+	return subscribers[offset:end], sum, nil
+}
+
+// 获取活跃订阅者
+func (model *SubscriberModel) GetActiveSubscribers() ([]int64, error) {
+	records, err := model.GetSubscriberRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	subscribers := make([]int64, 0, len(records))
+	for _, subscriber := range records {
+		if !subscriber.Blocked {
+			subscribers = append(subscribers, subscriber.UserID)
+		}
+	}
+	return subscribers, nil
+}
+
 // 获取订阅者数量
 func (*SubscriberModel) GetSubscriberCount() (int, error) {
 	var count int
@@ -0,0 +1,568 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+	"luckybot/app/fmath"
+	"luckybot/app/storage"
+)
+
+// 差异类型
+const (
+	DiscrepancyVersionAmount  = "version_amount"  // 版本剩余金额与回放结果不符
+	DiscrepancyAccountAmount  = "account_amount"  // 账户可用余额与回放结果不符
+	DiscrepancyAccountLocked  = "account_locked"  // 账户锁定金额与回放结果不符
+	DiscrepancyPendingLocked  = "pending_locked"  // 锁定金额与未领红包及在途提现不符
+	DiscrepancyLuckyMoney     = "luckymoney"      // 红包锁定记录与领取情况不符
+	DiscrepancyDepositMissing = "deposit_missing" // 充值记录没有对应账户版本
+	DiscrepancyAssetTotal     = "asset_total"     // 资产总额与充值减提现不符
+)
+
+// 修复操作
+const (
+	RepairSetAmount     = "set_amount"     // 修正可用余额
+	RepairSetLocked     = "set_locked"     // 修正锁定金额
+	RepairCreditDeposit = "credit_deposit" // 补记充值
+	RepairReview        = "review"         // 人工核查
+)
+
+// 差异信息
+type Discrepancy struct {
+	Kind         string        `json:"kind"`                     // 差异类型
+	UserID       int64         `json:"user_id"`                  // 用户ID
+	Symbol       string        `json:"symbol"`                   // 代币符号
+	Expected     fmath.Decimal `json:"expected"`                 // 期望金额
+	Actual       fmath.Decimal `json:"actual"`                   // 实际金额
+	VersionIDs   []uint64      `json:"version_ids,omitempty"`    // 相关版本ID
+	LuckyMoneyID uint64        `json:"lucky_money_id,omitempty"` // 相关红包ID
+	TxID         string        `json:"txid,omitempty"`           // 相关交易ID
+}
+
+// 修复操作
+type RepairAction struct {
+	Action       string        `json:"action"`                   // 操作类型
+	UserID       int64         `json:"user_id"`                  // 用户ID
+	Symbol       string        `json:"symbol"`                   // 代币符号
+	From         fmath.Decimal `json:"from"`                     // 当前金额
+	To           fmath.Decimal `json:"to"`                       // 目标金额
+	LuckyMoneyID uint64        `json:"lucky_money_id,omitempty"` // 相关红包ID
+	TxID         string        `json:"txid,omitempty"`           // 相关交易ID
+}
+
+// 资产汇总
+type AssetSummary struct {
+	Symbol    string        `json:"symbol"`    // 代币符号
+	Amount    fmath.Decimal `json:"amount"`    // 可用总额
+	Locked    fmath.Decimal `json:"locked"`    // 锁定总额
+	Deposited fmath.Decimal `json:"deposited"` // 充值总额
+	Withdrawn fmath.Decimal `json:"withdrawn"` // 提现总额(含手续费)
+}
+
+// 对账报告
+type VerifyReport struct {
+	Accounts      int             `json:"accounts"`              // 账户数量
+	Versions      int             `json:"versions"`              // 版本数量
+	LuckyMoneys   int             `json:"luckymoneys"`           // 红包数量
+	Deposits      int             `json:"deposits"`              // 充值数量
+	Assets        []*AssetSummary `json:"assets"`                // 资产汇总
+	Discrepancies []*Discrepancy  `json:"discrepancies"`         // 差异列表
+	RepairPlan    []*RepairAction `json:"repair_plan,omitempty"` // 修复计划
+}
+
+// 账户索引
+type accountKey struct {
+	userID int64
+	symbol string
+}
+
+// 回放状态
+type replayState struct {
+	total       fmath.Decimal // 资产总额
+	locked      fmath.Decimal // 锁定金额
+	withdrawing fmath.Decimal // 在途提现
+	lastID      uint64        // 最新版本ID
+}
+
+// 红包锁定状态
+type luckyMoneyState struct {
+	base       LuckyMoney    // 红包信息
+	expired    bool          // 是否过期
+	locked     fmath.Decimal // 版本记录锁定金额
+	claimed    bool          // 是否有领取版本
+	versionIDs []uint64      // 相关版本ID
+}
+
+// 对账模型
+type VerifyModel struct {
+}
+
+// 计算锁定变化
+// 提现手续费单独记录在Fee中，需要计入锁定金额
+func lockedDelta(version *Version) fmath.Decimal {
+	delta := fmath.Zero(0)
+	if version.Locked != nil {
+		delta = *version.Locked
+	}
+	if version.Fee != nil {
+		switch version.Reason {
+		case ReasonWithdraw:
+			delta = delta.Add(*version.Fee)
+		case ReasonWithdrawFailure, ReasonWithdrawSuccess, ReasonWithdrawRejected:
+			delta = delta.Sub(*version.Fee)
+		}
+	}
+	return delta
+}
+
+// 执行对账
+// 在同一只读事务中遍历账户、账户版本、红包及充值记录，plan为true时生成修复计划
+func (model *VerifyModel) Verify(plan bool) (*VerifyReport, error) {
+	report := VerifyReport{
+		Assets:        make([]*AssetSummary, 0),
+		Discrepancies: make([]*Discrepancy, 0),
+	}
+	accounts := make(map[accountKey]*Account)
+	replays := make(map[accountKey]*replayState)
+	luckyMoneys := make(map[uint64]*luckyMoneyState)
+	depositVersions := make(map[string]bool)
+	deposits := make([]*depositRecord, 0)
+	assets := make(map[string]*AssetSummary)
+
+	getAsset := func(symbol string) *AssetSummary {
+		asset, ok := assets[symbol]
+		if !ok {
+			asset = &AssetSummary{
+				Symbol:    symbol,
+				Amount:    fmath.Zero(0),
+				Locked:    fmath.Zero(0),
+				Deposited: fmath.Zero(0),
+				Withdrawn: fmath.Zero(0),
+			}
+			assets[symbol] = asset
+		}
+		return asset
+	}
+
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		if report.Accounts, err = model.readAccounts(tx, accounts); err != nil {
+			return err
+		}
+		if report.LuckyMoneys, err = model.readLuckyMoneys(tx, luckyMoneys); err != nil {
+			return err
+		}
+		if deposits, err = model.readDeposits(tx); err != nil {
+			return err
+		}
+		report.Deposits = len(deposits)
+
+		// 回放账户版本
+		return foreachVersions(tx, func(userID int64, version *Version) {
+			report.Versions++
+			key := accountKey{userID: userID, symbol: version.Symbol}
+			state, ok := replays[key]
+			if !ok {
+				state = &replayState{
+					total:       fmath.Zero(0),
+					locked:      fmath.Zero(0),
+					withdrawing: fmath.Zero(0),
+				}
+				replays[key] = state
+			}
+
+			delta := lockedDelta(version)
+			if version.Balance != nil {
+				state.total = state.total.Add(*version.Balance)
+			}
+			state.locked = state.locked.Add(delta)
+			state.lastID = version.ID
+
+			// 检查剩余金额
+			available := state.total.Sub(state.locked)
+			if available.Cmp(version.Amount) != 0 {
+				report.Discrepancies = append(report.Discrepancies, &Discrepancy{
+					Kind:       DiscrepancyVersionAmount,
+					UserID:     userID,
+					Symbol:     version.Symbol,
+					Expected:   available,
+					Actual:     version.Amount,
+					VersionIDs: []uint64{version.ID},
+				})
+				state.total = version.Amount.Add(state.locked)
+			}
+
+			switch version.Reason {
+			case ReasonDeposit, ReasonSystem:
+				if version.Balance != nil {
+					asset := getAsset(version.Symbol)
+					asset.Deposited = asset.Deposited.Add(*version.Balance)
+				}
+				if version.Reason == ReasonDeposit && version.RefTxID != nil {
+					depositVersions[*version.RefTxID] = true
+				}
+			case ReasonWithdraw, ReasonWithdrawFailure, ReasonWithdrawRejected:
+				state.withdrawing = state.withdrawing.Add(delta)
+			case ReasonWithdrawSuccess:
+				state.withdrawing = state.withdrawing.Add(delta)
+				if version.Balance != nil {
+					asset := getAsset(version.Symbol)
+					asset.Withdrawn = asset.Withdrawn.Sub(*version.Balance)
+				}
+			case ReasonGive, ReasonGiveBack, ReasonClaimed:
+				if version.RefLuckyMoneyID == nil {
+					break
+				}
+				if lm, ok := luckyMoneys[*version.RefLuckyMoneyID]; ok {
+					lm.locked = lm.locked.Add(delta)
+					lm.versionIDs = append(lm.versionIDs, version.ID)
+					if version.Reason == ReasonClaimed {
+						lm.claimed = true
+					}
+				}
+			}
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 检查红包锁定金额
+	pending := make(map[accountKey]fmath.Decimal)
+	for _, id := range sortedLuckyMoneyIDs(luckyMoneys) {
+		lm := luckyMoneys[id]
+		key := accountKey{userID: lm.base.SenderID, symbol: lm.base.Asset}
+
+		// 旧版本领取红包时没有记录发送者版本
+		if !lm.claimed && lm.base.Received.Sign() > 0 {
+			lm.locked = lm.locked.Sub(lm.base.Received)
+			if state, ok := replays[key]; ok {
+				state.total = state.total.Sub(lm.base.Received)
+				state.locked = state.locked.Sub(lm.base.Received)
+			}
+		}
+
+		remainder := fmath.Zero(0)
+		if !lm.expired {
+			total := lm.base.Amount
+			if !lm.base.Lucky {
+				total = total.Mul(int64(lm.base.Number))
+			}
+			remainder = total.Sub(lm.base.Received)
+			if value, ok := pending[key]; ok {
+				pending[key] = value.Add(remainder)
+			} else {
+				pending[key] = remainder
+			}
+		}
+
+		if lm.locked.Cmp(remainder) != 0 {
+			report.Discrepancies = append(report.Discrepancies, &Discrepancy{
+				Kind:         DiscrepancyLuckyMoney,
+				UserID:       lm.base.SenderID,
+				Symbol:       lm.base.Asset,
+				Expected:     remainder,
+				Actual:       lm.locked,
+				VersionIDs:   lm.versionIDs,
+				LuckyMoneyID: lm.base.ID,
+			})
+			if plan {
+				report.RepairPlan = append(report.RepairPlan, &RepairAction{
+					Action:       RepairReview,
+					UserID:       lm.base.SenderID,
+					Symbol:       lm.base.Asset,
+					From:         lm.locked,
+					To:           remainder,
+					LuckyMoneyID: lm.base.ID,
+				})
+			}
+		}
+	}
+
+	// 检查账户余额
+	for _, key := range sortedAccountKeys(accounts, replays) {
+		account, ok := accounts[key]
+		if !ok {
+			account = &Account{Symbol: key.symbol, Amount: fmath.Zero(0), Locked: fmath.Zero(0)}
+		}
+		asset := getAsset(key.symbol)
+		asset.Amount = asset.Amount.Add(account.Amount)
+		asset.Locked = asset.Locked.Add(account.Locked)
+
+		state, ok := replays[key]
+		if !ok {
+			state = &replayState{total: fmath.Zero(0), locked: fmath.Zero(0), withdrawing: fmath.Zero(0)}
+		}
+		var versionIDs []uint64
+		if state.lastID > 0 {
+			versionIDs = []uint64{state.lastID}
+		}
+
+		available := state.total.Sub(state.locked)
+		if available.Cmp(account.Amount) != 0 {
+			report.Discrepancies = append(report.Discrepancies, &Discrepancy{
+				Kind:       DiscrepancyAccountAmount,
+				UserID:     key.userID,
+				Symbol:     key.symbol,
+				Expected:   available,
+				Actual:     account.Amount,
+				VersionIDs: versionIDs,
+			})
+			if plan {
+				report.RepairPlan = append(report.RepairPlan, &RepairAction{
+					Action: RepairSetAmount,
+					UserID: key.userID,
+					Symbol: key.symbol,
+					From:   account.Amount,
+					To:     available,
+				})
+			}
+		}
+
+		if state.locked.Cmp(account.Locked) != 0 {
+			report.Discrepancies = append(report.Discrepancies, &Discrepancy{
+				Kind:       DiscrepancyAccountLocked,
+				UserID:     key.userID,
+				Symbol:     key.symbol,
+				Expected:   state.locked,
+				Actual:     account.Locked,
+				VersionIDs: versionIDs,
+			})
+			if plan {
+				report.RepairPlan = append(report.RepairPlan, &RepairAction{
+					Action: RepairSetLocked,
+					UserID: key.userID,
+					Symbol: key.symbol,
+					From:   account.Locked,
+					To:     state.locked,
+				})
+			}
+		}
+
+		expected := state.withdrawing
+		if value, ok := pending[key]; ok {
+			expected = expected.Add(value)
+		}
+		if expected.Cmp(account.Locked) != 0 {
+			report.Discrepancies = append(report.Discrepancies, &Discrepancy{
+				Kind:       DiscrepancyPendingLocked,
+				UserID:     key.userID,
+				Symbol:     key.symbol,
+				Expected:   expected,
+				Actual:     account.Locked,
+				VersionIDs: versionIDs,
+			})
+		}
+	}
+
+	// 检查充值记录
+	for _, deposit := range deposits {
+		if depositVersions[deposit.TxID] {
+			continue
+		}
+		userID, _ := strconv.ParseInt(deposit.Memo, 10, 64)
+		amount, _ := fmath.ParseRound(deposit.Amount, fmath.MaxPrecision)
+		report.Discrepancies = append(report.Discrepancies, &Discrepancy{
+			Kind:     DiscrepancyDepositMissing,
+			UserID:   userID,
+			Symbol:   deposit.Asset,
+			Expected: amount,
+			Actual:   fmath.Zero(0),
+			TxID:     deposit.TxID,
+		})
+		if plan {
+			report.RepairPlan = append(report.RepairPlan, &RepairAction{
+				Action: RepairCreditDeposit,
+				UserID: userID,
+				Symbol: deposit.Asset,
+				From:   fmath.Zero(0),
+				To:     amount,
+				TxID:   deposit.TxID,
+			})
+		}
+	}
+
+	// 检查资产总额
+	symbols := make([]string, 0, len(assets))
+	for symbol := range assets {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		asset := assets[symbol]
+		report.Assets = append(report.Assets, asset)
+		total := asset.Amount.Add(asset.Locked)
+		expected := asset.Deposited.Sub(asset.Withdrawn)
+		if total.Cmp(expected) != 0 {
+			report.Discrepancies = append(report.Discrepancies, &Discrepancy{
+				Kind:     DiscrepancyAssetTotal,
+				Symbol:   symbol,
+				Expected: expected,
+				Actual:   total,
+			})
+		}
+	}
+	return &report, nil
+}
+
+// 读取账户信息
+func (model *VerifyModel) readAccounts(tx *bolt.Tx, accounts map[accountKey]*Account) (int, error) {
+	count := 0
+	root, err := storage.GetBucketIfExists(tx, "accounts")
+	if err != nil {
+		if err != storage.ErrNoBucket {
+			return 0, err
+		}
+		return 0, nil
+	}
+	err = root.ForEach(func(k, v []byte) error {
+		userID, err := strconv.ParseInt(string(k), 10, 64)
+		if err != nil || v != nil {
+			return nil
+		}
+		return root.Bucket(k).ForEach(func(symbol, jsb []byte) error {
+			var account Account
+			if err := json.Unmarshal(jsb, &account); err != nil {
+				return err
+			}
+			count++
+			accounts[accountKey{userID: userID, symbol: string(symbol)}] = &account
+			return nil
+		})
+	})
+	return count, err
+}
+
+// 读取红包信息
+func (model *VerifyModel) readLuckyMoneys(tx *bolt.Tx, luckyMoneys map[uint64]*luckyMoneyState) (int, error) {
+	root, err := storage.GetBucketIfExists(tx, "luckymoney")
+	if err != nil {
+		if err != storage.ErrNoBucket {
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	err = root.ForEach(func(k, v []byte) error {
+		id, err := strconv.ParseUint(string(k), 10, 64)
+		if err != nil || v != nil {
+			return nil
+		}
+
+		bucket := root.Bucket(k)
+		jsb := bucket.Get([]byte("base"))
+		if jsb == nil {
+			return nil
+		}
+
+		state := luckyMoneyState{locked: fmath.Zero(0)}
+		if err = json.Unmarshal(jsb, &state.base); err != nil {
+			return err
+		}
+		state.expired = bucket.Get([]byte("expired")) != nil
+		luckyMoneys[id] = &state
+		return nil
+	})
+	return len(luckyMoneys), err
+}
+
+// 充值记录
+type depositRecord struct {
+	TxID   string `json:"txid"`   // 交易ID
+	Asset  string `json:"asset"`  // 资产名称
+	Amount string `json:"amount"` // 充值金额
+	Memo   string `json:"memo"`   // 备注信息
+}
+
+// 读取充值记录
+func (model *VerifyModel) readDeposits(tx *bolt.Tx) ([]*depositRecord, error) {
+	deposits := make([]*depositRecord, 0)
+	bucket, err := storage.GetBucketIfExists(tx, "deposits")
+	if err != nil {
+		if err != storage.ErrNoBucket {
+			return nil, err
+		}
+		return deposits, nil
+	}
+
+	err = bucket.ForEach(func(k, v []byte) error {
+		var deposit depositRecord
+		if err := json.Unmarshal(v, &deposit); err != nil {
+			return err
+		}
+		deposit.TxID = string(k)
+		deposits = append(deposits, &deposit)
+		return nil
+	})
+	return deposits, err
+}
+
+// 按顺序遍历所有账户版本
+func foreachVersions(tx *bolt.Tx, callback func(int64, *Version)) error {
+	root, err := storage.GetBucketIfExists(tx, "account_versions")
+	if err != nil {
+		if err != storage.ErrNoBucket {
+			return err
+		}
+		return nil
+	}
+
+	return root.ForEach(func(k, v []byte) error {
+		userID, err := strconv.ParseInt(string(k), 10, 64)
+		if err != nil || v != nil {
+			return nil
+		}
+
+		bucket := root.Bucket(k)
+		for i := uint64(1); i <= bucket.Sequence(); i++ {
+			jsb := bucket.Get([]byte(strconv.FormatUint(i, 10)))
+			if jsb == nil {
+				continue
+			}
+
+			var version Version
+			if err = json.Unmarshal(jsb, &version); err != nil {
+				return err
+			}
+			callback(userID, &version)
+		}
+		return nil
+	})
+}
+
+// 排序红包ID
+func sortedLuckyMoneyIDs(luckyMoneys map[uint64]*luckyMoneyState) []uint64 {
+	ids := make([]uint64, 0, len(luckyMoneys))
+	for id := range luckyMoneys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// 排序账户索引
+func sortedAccountKeys(accounts map[accountKey]*Account, replays map[accountKey]*replayState) []accountKey {
+	set := make(map[accountKey]bool)
+	for key := range accounts {
+		set[key] = true
+	}
+	for key := range replays {
+		set[key] = true
+	}
+
+	keys := make([]accountKey, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].userID != keys[j].userID {
+			return keys[i].userID < keys[j].userID
+		}
+		return keys[i].symbol < keys[j].symbol
+	})
+	return keys
+}
@@ -0,0 +1,457 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"luckybot/app/fmath"
+	"luckybot/app/storage"
+)
+
+// 提现状态
+type WithdrawState int
+
+const (
+	_                 WithdrawState = iota
+	WithdrawPending                 // 等待处理
+	WithdrawSubmitted               // 已提交脚本
+	WithdrawConfirmed               // 提现成功
+	WithdrawFailed                  // 提现失败
+	WithdrawReview                  // 等待审核
+	WithdrawRejected                // 审核拒绝
+	WithdrawTimedOut                // 超时待确认
+)
+
+// 是否终结状态
+func (state WithdrawState) Finished() bool {
+	return state == WithdrawConfirmed || state == WithdrawFailed || state == WithdrawRejected
+}
+
+// 提现记录
+type Withdraw struct {
+	ID        uint64        `json:"id"`              // 提现ID
+	UserID    int64         `json:"user_id"`         // 用户ID
+	Symbol    string        `json:"symbol"`          // 代币符号
+	Address   string        `json:"address"`         // 提现地址
+	Memo      string        `json:"memo,omitempty"`  // 备注信息
+	Amount    fmath.Decimal `json:"amount"`          // 提现数量
+	Fee       fmath.Decimal `json:"fee"`             // 手续费
+	State     WithdrawState `json:"state"`           // 提现状态
+	TxID      string        `json:"txid,omitempty"`  // 交易ID
+	Error     string        `json:"error,omitempty"` // 错误信息
+	CreatedAt int64         `json:"created_at"`      // 创建时间
+	UpdatedAt int64         `json:"updated_at"`      // 更新时间
+}
+
+var (
+	// 提现不存在
+	ErrWithdrawNotFound = errors.New("withdraw not found")
+	// 提现已结束
+	ErrWithdrawFinished = errors.New("withdraw finished")
+	// 提现等待审核
+	ErrWithdrawInReview = errors.New("withdraw in review")
+	// 提现不在审核中
+	ErrWithdrawNotInReview = errors.New("withdraw not in review")
+	// 提现不是超时状态
+	ErrWithdrawNotTimedOut = errors.New("withdraw not timed out")
+)
+
+// ********************** 结构图 **********************
+// {
+//	"withdraws": {
+// 		<id>: Withdraw	// 提现记录
+//	}
+// }
+// ***************************************************
+
+// 提现模型
+type WithdrawModel struct {
+}
+
+// 获取提现记录
+func (model *WithdrawModel) getWithdraw(tx *bolt.Tx, id uint64) (*Withdraw, error) {
+	bucket, err := storage.GetBucketIfExists(tx, "withdraws")
+	if err != nil {
+		if err != storage.ErrNoBucket {
+			return nil, err
+		}
+		return nil, ErrWithdrawNotFound
+	}
+
+	jsb := bucket.Get([]byte(strconv.FormatUint(id, 10)))
+	if jsb == nil {
+		return nil, ErrWithdrawNotFound
+	}
+
+	var withdraw Withdraw
+	if err = json.Unmarshal(jsb, &withdraw); err != nil {
+		return nil, err
+	}
+	return &withdraw, nil
+}
+
+// 关联备注信息
+func (withdraw *Withdraw) refMemo() *string {
+	if len(withdraw.Memo) == 0 {
+		return nil
+	}
+	return &withdraw.Memo
+}
+
+// 保存提现记录
+func (model *WithdrawModel) putWithdraw(tx *bolt.Tx, withdraw *Withdraw) error {
+	bucket, err := storage.EnsureBucketExists(tx, "withdraws")
+	if err != nil {
+		return err
+	}
+
+	withdraw.UpdatedAt = time.Now().UTC().Unix()
+	jsb, err := json.Marshal(withdraw)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(strconv.FormatUint(withdraw.ID, 10)), jsb)
+}
+
+// 创建提现
+// 提现数量与手续费在同一事务中锁定，review为true时等待人工审核
+func (model *WithdrawModel) NewWithdraw(withdraw *Withdraw, review bool) (*Withdraw, error) {
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		bucket, err := storage.EnsureBucketExists(tx, "withdraws")
+		if err != nil {
+			return err
+		}
+		withdraw.ID, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		// 锁定提现资金
+		journal := NewJournal(withdraw.Symbol).Lock(withdraw.UserID, withdraw.Amount.Add(withdraw.Fee)).
+			Record(withdraw.UserID, &Version{
+				Locked:        &withdraw.Amount,
+				Fee:           &withdraw.Fee,
+				Reason:        ReasonWithdraw,
+				RefAddress:    &withdraw.Address,
+				RefMemo:       withdraw.refMemo(),
+				RefWithdrawID: &withdraw.ID,
+			})
+		ledger := LedgerModel{}
+		if _, err = ledger.post(tx, journal); err != nil {
+			return err
+		}
+
+		withdraw.State = WithdrawPending
+		if review {
+			withdraw.State = WithdrawReview
+		}
+		withdraw.CreatedAt = time.Now().UTC().Unix()
+		return model.putWithdraw(tx, withdraw)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return withdraw, nil
+}
+
+// 获取提现
+func (model *WithdrawModel) GetWithdraw(id uint64) (*Withdraw, error) {
+	var withdraw *Withdraw
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		withdraw, err = model.getWithdraw(tx, id)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return withdraw, nil
+}
+
+// 获取未完成提现
+func (model *WithdrawModel) GetUnfinished() ([]*Withdraw, error) {
+	withdraws := make([]*Withdraw, 0)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "withdraws")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		for i := uint64(1); i <= bucket.Sequence(); i++ {
+			jsb := bucket.Get([]byte(strconv.FormatUint(i, 10)))
+			if jsb == nil {
+				continue
+			}
+
+			var withdraw Withdraw
+			if err = json.Unmarshal(jsb, &withdraw); err != nil {
+				return err
+			}
+			if !withdraw.State.Finished() {
+				withdraws = append(withdraws, &withdraw)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return withdraws, nil
+}
+
+// 获取提现列表
+// 按ID倒序返回指定状态的提现，state为0时返回全部
+func (model *WithdrawModel) GetWithdraws(state WithdrawState, offset, limit uint) ([]*Withdraw, uint, error) {
+	sum := uint(0)
+	withdraws := make([]*Withdraw, 0)
+	err := storage.DB.View(func(tx *bolt.Tx) error {
+		bucket, err := storage.GetBucketIfExists(tx, "withdraws")
+		if err != nil {
+			if err != storage.ErrNoBucket {
+				return err
+			}
+			return nil
+		}
+
+		for i := bucket.Sequence(); i > 0; i-- {
+			jsb := bucket.Get([]byte(strconv.FormatUint(i, 10)))
+			if jsb == nil {
+				continue
+			}
+
+			var withdraw Withdraw
+			if err = json.Unmarshal(jsb, &withdraw); err != nil {
+				return err
+			}
+			if state != 0 && withdraw.State != state {
+				continue
+			}
+
+			sum++
+			if sum > offset && uint(len(withdraws)) < limit {
+				withdraws = append(withdraws, &withdraw)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, 0, err
+	}
+	return withdraws, sum, nil
+}
+
+// 审核通过
+func (model *WithdrawModel) Approve(id uint64) (*Withdraw, error) {
+	var withdraw *Withdraw
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		withdraw, err = model.getWithdraw(tx, id)
+		if err != nil {
+			return err
+		}
+		if withdraw.State != WithdrawReview {
+			return ErrWithdrawNotInReview
+		}
+
+		withdraw.State = WithdrawPending
+		return model.putWithdraw(tx, withdraw)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return withdraw, nil
+}
+
+// 审核拒绝
+// 解锁资金并写入账户版本
+func (model *WithdrawModel) Reject(id uint64, reason string) (*Withdraw, *Version, error) {
+	var withdraw *Withdraw
+	var version *Version
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		withdraw, err = model.getWithdraw(tx, id)
+		if err != nil {
+			return err
+		}
+		if withdraw.State != WithdrawReview {
+			return ErrWithdrawNotInReview
+		}
+
+		locked := withdraw.Amount.Neg()
+		version = &Version{
+			Locked:        &locked,
+			Fee:           &withdraw.Fee,
+			Reason:        ReasonWithdrawRejected,
+			RefAddress:    &withdraw.Address,
+			RefMemo:       withdraw.refMemo(),
+			RefWithdrawID: &withdraw.ID,
+		}
+		journal := NewJournal(withdraw.Symbol).Unlock(withdraw.UserID, withdraw.Amount.Add(withdraw.Fee)).
+			Record(withdraw.UserID, version)
+		ledger := LedgerModel{}
+		if _, err = ledger.post(tx, journal); err != nil {
+			return err
+		}
+
+		withdraw.State = WithdrawRejected
+		withdraw.Error = reason
+		return model.putWithdraw(tx, withdraw)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return withdraw, version, nil
+}
+
+// 标记已提交
+func (model *WithdrawModel) SetSubmitted(id uint64) (*Withdraw, error) {
+	var withdraw *Withdraw
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		withdraw, err = model.getWithdraw(tx, id)
+		if err != nil {
+			return err
+		}
+		if withdraw.State.Finished() {
+			return ErrWithdrawFinished
+		}
+		if withdraw.State == WithdrawReview {
+			return ErrWithdrawInReview
+		}
+
+		withdraw.State = WithdrawSubmitted
+		return model.putWithdraw(tx, withdraw)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return withdraw, nil
+}
+
+// 标记提现超时
+// 超时不代表失败，资金保持锁定，等待脚本迟到的结果或人工确认
+func (model *WithdrawModel) SetTimedOut(id uint64, reason string) (*Withdraw, error) {
+	var withdraw *Withdraw
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		withdraw, err = model.getWithdraw(tx, id)
+		if err != nil {
+			return err
+		}
+		if withdraw.State.Finished() {
+			return ErrWithdrawFinished
+		}
+		if withdraw.State == WithdrawReview {
+			return ErrWithdrawInReview
+		}
+
+		withdraw.State = WithdrawTimedOut
+		withdraw.Error = reason
+		return model.putWithdraw(tx, withdraw)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return withdraw, nil
+}
+
+// 确认提现成功
+// 扣除锁定资金并写入账户版本
+func (model *WithdrawModel) Confirm(id uint64, txid string) (*Withdraw, *Version, error) {
+	var withdraw *Withdraw
+	var version *Version
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		withdraw, err = model.getWithdraw(tx, id)
+		if err != nil {
+			return err
+		}
+		if withdraw.State.Finished() {
+			return ErrWithdrawFinished
+		}
+
+		balance := withdraw.Amount.Add(withdraw.Fee).Neg()
+		locked := withdraw.Amount.Neg()
+		version = &Version{
+			Balance:       &balance,
+			Locked:        &locked,
+			Fee:           &withdraw.Fee,
+			Reason:        ReasonWithdrawSuccess,
+			RefAddress:    &withdraw.Address,
+			RefMemo:       withdraw.refMemo(),
+			RefTxID:       &txid,
+			RefWithdrawID: &withdraw.ID,
+		}
+		journal := NewJournal(withdraw.Symbol).Withdraw(withdraw.UserID, withdraw.Amount.Add(withdraw.Fee)).
+			Record(withdraw.UserID, version)
+		ledger := LedgerModel{}
+		if _, err = ledger.post(tx, journal); err != nil {
+			return err
+		}
+
+		withdraw.State = WithdrawConfirmed
+		withdraw.TxID = txid
+		return model.putWithdraw(tx, withdraw)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return withdraw, version, nil
+}
+
+// 标记提现失败
+// 解锁资金并写入账户版本
+func (model *WithdrawModel) Fail(id uint64, reason string) (*Withdraw, *Version, error) {
+	var withdraw *Withdraw
+	var version *Version
+	err := storage.DB.Update(func(tx *bolt.Tx) error {
+		var err error
+		withdraw, err = model.getWithdraw(tx, id)
+		if err != nil {
+			return err
+		}
+		if withdraw.State.Finished() {
+			return ErrWithdrawFinished
+		}
+
+		locked := withdraw.Amount.Neg()
+		version = &Version{
+			Locked:        &locked,
+			Fee:           &withdraw.Fee,
+			Reason:        ReasonWithdrawFailure,
+			RefAddress:    &withdraw.Address,
+			RefMemo:       withdraw.refMemo(),
+			RefWithdrawID: &withdraw.ID,
+		}
+		journal := NewJournal(withdraw.Symbol).Unlock(withdraw.UserID, withdraw.Amount.Add(withdraw.Fee)).
+			Record(withdraw.UserID, version)
+		ledger := LedgerModel{}
+		if _, err = ledger.post(tx, journal); err != nil {
+			return err
+		}
+
+		withdraw.State = WithdrawFailed
+		withdraw.Error = reason
+		return model.putWithdraw(tx, withdraw)
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+	return withdraw, version, nil
+}
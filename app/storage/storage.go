@@ -3,6 +3,7 @@ package storage
 import (
 	"errors"
 	"io"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
@@ -22,6 +23,14 @@ func Connect(path string) error {
 	return err
 }
 
+// 以只读方式连接到数据库
+// 数据库被其它进程占用时等待timeout后返回错误
+func ConnectReadOnly(path string, timeout time.Duration) error {
+	var err error
+	DB, err = bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: timeout})
+	return err
+}
+
 // 关闭连接
 func Close() error {
 	return DB.Close()
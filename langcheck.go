@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"luckybot/app/config"
+)
+
+// 语言包检查命令
+// 用法: luckybot lang-check [-dir lang] [-ref zh_CN] [-strict] [-json]
+func langCheck(args []string) int {
+	flags := flag.NewFlagSet("lang-check", flag.ExitOnError)
+	dir := flags.String("dir", "lang", "language packs directory")
+	ref := flags.String("ref", "zh_CN", "reference language code")
+	strict := flags.Bool("strict", false, "treat extra keys as errors")
+	asJSON := flags.Bool("json", false, "print issues as json")
+	flags.Parse(args)
+
+	// 检查语言包
+	issues, err := config.CheckLanguages(*dir, *ref)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to check language packs, %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		jsb, err := json.MarshalIndent(issues, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to encode issues, %v\n", err)
+			return 2
+		}
+		fmt.Println(string(jsb))
+	} else {
+		for i := range issues {
+			level := "warning"
+			if issues[i].IsError() {
+				level = "error"
+			}
+			fmt.Printf("%s: %s\n", level, issues[i].String())
+		}
+	}
+
+	for i := range issues {
+		if *strict || issues[i].IsError() {
+			return 1
+		}
+	}
+	return 0
+}
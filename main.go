@@ -1,42 +1,95 @@
 package main
 
 import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/vrecan/death"
 	"github.com/zhangpanyi/basebot/logger"
+	"github.com/zhangpanyi/basebot/telegram/methods"
 	"github.com/zhangpanyi/basebot/telegram/updater"
 	"luckybot/app/admin"
 	"luckybot/app/config"
+	"luckybot/app/dispatcher"
 	"luckybot/app/future"
 	"luckybot/app/logic"
 	"luckybot/app/logic/botext"
+	"luckybot/app/logic/broadcast"
 	"luckybot/app/logic/context"
 	"luckybot/app/logic/deposit"
 	"luckybot/app/logic/pusher"
 	"luckybot/app/logic/scriptengine"
+	"luckybot/app/logic/withdraw"
 	"luckybot/app/monitor"
 	poll "luckybot/app/poller"
 	"luckybot/app/storage"
+	"luckybot/app/storage/models"
 )
 
 func main() {
+	// 执行子命令
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(verify(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lang-check" {
+		os.Exit(langCheck(os.Args[2:]))
+	}
+
+	// 解析命令行参数
+	configPath := flag.String("config", defaultConfigPath(), "config file path")
+	check := flag.Bool("check", false, "check config and exit")
+	flag.Parse()
+
 	// 加载配置文件
-	config.LoadConfig("server.yml")
+	if err := config.LoadConfig(*configPath); err != nil {
+		printConfigError(err)
+		os.Exit(2)
+	}
+	if *check {
+		fmt.Println("Config OK")
+		return
+	}
 
 	// 初始化日志库
 	serveCfg := config.GetServe()
 	logger.CreateLoggerOnce(logger.DebugLevel, logger.InfoLevel)
 
+	// 输出语言包警告
+	issues, err := config.CheckLanguages(serveCfg.Languages, serveCfg.DefaultLanguage)
+	if err == nil {
+		for i := range issues {
+			logger.Warnf("Language pack issue, %s", issues[i].String())
+		}
+	}
+
 	// 连接到数据库
-	err := storage.Connect(serveCfg.BolTDBPath)
+	err = storage.Connect(serveCfg.BolTDBPath)
 	if err != nil {
 		logger.Panic(err)
 	}
 
+	// 迁移金额数据
+	precisions := make(map[string]int)
+	for _, asset := range serveCfg.Assets {
+		precisions[asset.Symbol] = asset.Precision
+	}
+	migrationModel := models.MigrationModel{}
+	migrated, err := migrationModel.MigrateDecimal(precisions)
+	if err != nil {
+		logger.Panic(err)
+	}
+	if migrated {
+		logger.Infof("Migrate amounts to fixed-point decimal finished")
+	}
+
 	// 状态上下文管理
 	context.CreateManagerOnce(16)
 
@@ -46,9 +99,38 @@ func main() {
 	// 创建Lua脚本引擎
 	scriptengine.NewScriptEngineOnce()
 
+	// 创建更新分发器
+	dispatcher.NewDispatcherOnce(serveCfg.DispatchWorkers, serveCfg.DispatchQueueSize, logic.NewUpdate)
+
+	// 监听HTTP端口
+	// Webhook模式下必须先监听端口再向电报注册，服务启动前的请求在连接队列中等待
+	addr := serveCfg.Host + ":" + strconv.Itoa(serveCfg.Port)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Panicf("Failed to listen, %v, %v", addr, err)
+	}
+	webhook := serveCfg.Webhook
+	if webhook.Enable && len(webhook.PrivateKey) > 0 {
+		certificate, err := tls.LoadX509KeyPair(webhook.Certificate, webhook.PrivateKey)
+		if err != nil {
+			logger.Panicf("Failed to load certificate, %v", err)
+		}
+		listener = tls.NewListener(listener, &tls.Config{Certificates: []tls.Certificate{certificate}})
+	}
+
 	// 创建机器人轮询器
-	poller := poll.NewPoller(serveCfg.APIAccess)
-	bot, err := poller.StartPoll(serveCfg.Token, logic.NewUpdate)
+	router := mux.NewRouter()
+	unhealthyAfter := time.Duration(serveCfg.UnhealthyAfter) * time.Second
+	if unhealthyAfter == 0 {
+		unhealthyAfter = time.Minute * 2
+	}
+	poller := poll.NewPoller(serveCfg.APIAccess, unhealthyAfter)
+	var bot *methods.BotExt
+	if webhook.Enable {
+		bot, err = poller.StartWebhook(serveCfg.Token, webhook, router, dispatcher.Dispatch)
+	} else {
+		bot, err = poller.StartPoll(serveCfg.Token, dispatcher.Dispatch)
+	}
 	if err != nil {
 		logger.Panic(err)
 	}
@@ -62,19 +144,28 @@ func main() {
 	// 运行推送服务
 	pusher.ServiceStart(pool)
 
+	// 运行广播服务
+	broadcast.ServiceStart(serveCfg.BroadcastRate)
+
+	// 恢复未完成提现
+	withdraw.Resume()
+
 	// 启动HTTP服务器
-	router := mux.NewRouter()
 	admin.InitRoute(router)
 	router.HandleFunc("/deposit", deposit.HandleDeposit)
-	addr := serveCfg.Host + ":" + strconv.Itoa(serveCfg.Port)
+	router.HandleFunc("/health", poll.HandleHealth)
 	go func() {
 		s := &http.Server{
 			Addr:    addr,
 			Handler: router,
 		}
-		if err = s.ListenAndServe(); err != nil {
-			logger.Panicf("Failed to listen and serve, %v, %v", addr, err)
+		err := s.Serve(listener)
+		if webhook.Enable {
+			if e := poller.DeleteWebhook(serveCfg.Token); e != nil {
+				logger.Errorf("Failed to delete webhook, %v", e)
+			}
 		}
+		logger.Panicf("Failed to serve, %v, %v", addr, err)
 	}()
 	logger.Infof("Lucky money server started")
 
@@ -86,3 +177,24 @@ func main() {
 		logger.Infof("Lucky money server stoped")
 	})
 }
+
+// 默认配置文件路径
+func defaultConfigPath() string {
+	if path, ok := os.LookupEnv("LUCKYBOT_CONFIG"); ok {
+		return path
+	}
+	return "server.yml"
+}
+
+// 输出配置错误
+func printConfigError(err error) {
+	errs, ok := err.(config.ConfigError)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Failed to load config, %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Failed to load config, %d errors:\n", len(errs))
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "  - %v\n", err)
+	}
+}
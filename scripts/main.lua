@@ -8,16 +8,18 @@ end
 
 -- 账户是否有效
 -- @param address <string> 地址
+-- @param symbol <string> 货币符号
 -- @return <boolean>
-function valid_address(address)
+function valid_address(address, symbol)
     return true
 end
 
 -- 获取充值地址
 -- @param userid <string> 用户ID
+-- @param symbol <string> 货币符号
 -- @return address <string>
 -- @return memo <string or nil>
-function deposit_address(userid)
+function deposit_address(userid, symbol)
     return 'test', userid
 end
 
@@ -26,7 +28,9 @@ end
 -- @param symbol <string> 货币符号
 -- @param amount <string> 提现金额
 -- @param future <Future> 处理完成必须调用set_result(txid, error)方法
-function on_withdraw(to, symbol, amount, future)
+-- @param memo <string> 备注信息，没有备注时为空字符串
+-- @param withdraw_id <string> 提现ID，同一笔提现重复调用时不变，可作为幂等键防止重复转账
+function on_withdraw(to, symbol, amount, future, memo, withdraw_id)
     future:set_result(nil, 'unrealized')
 end
 
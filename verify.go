@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"luckybot/app/config"
+	"luckybot/app/storage"
+	"luckybot/app/storage/models"
+)
+
+// 对账命令
+// 用法: luckybot verify [-config server.yml] [-plan]
+func verify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath(), "config file path")
+	plan := flags.Bool("plan", false, "emit repair plan for discrepancies")
+	flags.Parse(args)
+
+	// 加载配置文件
+	if err := config.LoadConfig(*configPath); err != nil {
+		printConfigError(err)
+		return 2
+	}
+	serveCfg := config.GetServe()
+
+	// 只读方式连接数据库
+	err := storage.ConnectReadOnly(serveCfg.BolTDBPath, time.Second*3)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open database, %v\n", err)
+		return 2
+	}
+	defer storage.Close()
+
+	// 检查账户数据
+	model := models.VerifyModel{}
+	report, err := model.Verify(*plan)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to verify, %v\n", err)
+		return 2
+	}
+
+	jsb, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode report, %v\n", err)
+		return 2
+	}
+	fmt.Println(string(jsb))
+
+	if len(report.Discrepancies) > 0 {
+		return 1
+	}
+	return 0
+}